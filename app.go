@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v73/github"
+	"golang.org/x/oauth2"
+)
+
+// appTokenSource mints installation access tokens for a GitHub App. Each token
+// is valid for an hour, so it is meant to be wrapped in
+// oauth2.ReuseTokenSource, which asks for a new one once it expires.
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	// baseURL is the GitHub Enterprise Server URL, or empty for github.com.
+	baseURL string
+}
+
+// newAppTokenSource reads the app's PEM-encoded private key from keyFile.
+func newAppTokenSource(appID, installationID int64, keyFile, baseURL string) (*appTokenSource, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM-encoded private key", keyFile)
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, fmt.Errorf("failed to parse private key in %s: %v", keyFile, err)
+		}
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, errors.New("the private key is not an RSA key")
+		}
+	}
+
+	return &appTokenSource{appID: appID, installationID: installationID, key: key, baseURL: baseURL}, nil
+}
+
+// Token creates a new installation access token, authenticating as the app
+// with a short-lived JWT.
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.jwt(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign app JWT: %v", err)
+	}
+
+	client := github.NewClient(nil).WithAuthToken(jwt)
+	if s.baseURL != "" {
+		if client, err = client.WithEnterpriseURLs(s.baseURL, s.baseURL); err != nil {
+			return nil, err
+		}
+	}
+	installationToken, _, err := client.Apps.CreateInstallationToken(context.Background(), s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %v", err)
+	}
+	return &oauth2.Token{
+		AccessToken: installationToken.GetToken(),
+		Expiry:      installationToken.GetExpiresAt().Time,
+	}, nil
+}
+
+// jwt returns a JWT identifying the app, signed with RS256 as GitHub requires.
+// It is backdated by a minute to allow for clock drift and expires after nine
+// minutes, within GitHub's ten-minute maximum.
+func (s *appTokenSource) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestKey writes a new RSA private key, PEM-encoded as GitHub hands it
+// out, and returns its path.
+func writeTestKey(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+func decodeSegment(t *testing.T, segment string, v any) {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		t.Fatalf("segment %q is not base64url: %v", segment, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("segment %q is not JSON: %v", data, err)
+	}
+}
+
+func TestAppJWT(t *testing.T) {
+	path, key := writeTestKey(t)
+	source, err := newAppTokenSource(42, 7, path, "")
+	if err != nil {
+		t.Fatalf("newAppTokenSource: %v", err)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	jwt, err := source.jwt(now)
+	if err != nil {
+		t.Fatalf("jwt: %v", err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts, want 3", len(parts))
+	}
+
+	var header map[string]string
+	decodeSegment(t, parts[0], &header)
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("header %v, want RS256 JWT", header)
+	}
+
+	var claims map[string]int64
+	decodeSegment(t, parts[1], &claims)
+	want := map[string]int64{"iss": 42, "iat": now.Unix() - 60, "exp": now.Unix() + 9*60}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("claim %s = %d, want %d", name, claims[name], value)
+		}
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("signature is not base64url: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify with the app's public key: %v", err)
+	}
+}
+
+func TestAppTokenSourcePKCS8Key(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := newAppTokenSource(1, 2, path, "")
+	if err != nil {
+		t.Fatalf("newAppTokenSource: %v", err)
+	}
+	if !source.key.Equal(key) {
+		t.Error("parsed key differs from the written key")
+	}
+
+	if err := os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newAppTokenSource(1, 2, path, ""); err == nil {
+		t.Error("a file without a PEM block was accepted")
+	}
+}
+
+func TestAppTokenSourceToken(t *testing.T) {
+	path, key := writeTestKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/app/installations/7/access_tokens" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if !ok || len(parts) != 3 {
+			t.Errorf("request not authenticated with a JWT: %q", r.Header.Get("Authorization"))
+		} else {
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				t.Errorf("JWT signature does not verify: %v", err)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"token": "ghs_installation", "expires_at": "2030-01-01T00:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	source, err := newAppTokenSource(42, 7, path, server.URL)
+	if err != nil {
+		t.Fatalf("newAppTokenSource: %v", err)
+	}
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token.AccessToken != "ghs_installation" || token.Expiry.Year() != 2030 {
+		t.Errorf("got token %q expiring %v", token.AccessToken, token.Expiry)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// attachmentURLRegex matches the URLs of images uploaded to issues and
+// comments on github.com, which live outside the repository and may disappear
+// with it.
+var attachmentURLRegex = regexp.MustCompile(`https://(?:github\.com/user-attachments/assets/[\w-]+|(?:private-)?user-images\.githubusercontent\.com/[^\s)"'<>]+|github\.com/[\w.-]+/[\w.-]+/assets/\d+/[\w-]+)`)
+
+// maxAttachmentSize is the largest attachment copied; GitHub's contents API
+// rejects larger files.
+const maxAttachmentSize = 100 << 20
+
+// copyAttachments copies every image attachment referenced in the bodies and
+// comments of issues into the target repository, on --attachments-branch under
+// --attachments-path, and rewrites the references to the copies. It runs once
+// before Phase 3 so that every later phase sees the rewritten text. An
+// attachment that cannot be copied keeps its original URL; the failure is
+// only returned with --fail-fast.
+func (im *importer) copyAttachments(ctx context.Context, issues []Issue) error {
+	if !im.dryRun {
+		if err := im.ensureAttachmentsBranch(ctx); err != nil {
+			return err
+		}
+	}
+
+	copied := make(map[string]string)
+	var firstErr error
+	rewrite := func(text string) string {
+		return attachmentURLRegex.ReplaceAllStringFunc(text, func(source string) string {
+			if newURL, ok := copied[source]; ok {
+				return newURL
+			}
+			if firstErr != nil {
+				return source
+			}
+			if im.dryRun {
+				infof("[dry-run] Would copy attachment %s to %s/%s on branch %s", source, im.targetRepo(), im.attachmentsPath, im.attachmentsBranch)
+				copied[source] = source
+				return source
+			}
+			newURL, err := im.copyAttachment(ctx, source)
+			if err != nil {
+				log.Printf("Failed to copy attachment %s: %v\n", source, err)
+				im.report.AttachmentsFailed = append(im.report.AttachmentsFailed, source)
+				if im.failFast || ctx.Err() != nil {
+					firstErr = fmt.Errorf("failed to copy attachment %s: %v", source, err)
+				}
+				return source
+			}
+			copied[source] = newURL
+			im.report.AttachmentsCopied++
+			return newURL
+		})
+	}
+
+	for i := range issues {
+		issues[i].Body = rewrite(issues[i].Body)
+		for j := range issues[i].Comments {
+			issues[i].Comments[j].Body = rewrite(issues[i].Comments[j].Body)
+		}
+	}
+	return firstErr
+}
+
+// ensureAttachmentsBranch creates --attachments-branch from the default
+// branch of the target repository unless it already exists.
+func (im *importer) ensureAttachmentsBranch(ctx context.Context) error {
+	_, _, err := im.client.Repositories.GetBranch(ctx, im.owner, im.repo, im.attachmentsBranch, 1)
+	if err == nil {
+		return nil
+	}
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to look up branch %s: %v", im.attachmentsBranch, err)
+	}
+
+	repository, _, err := im.client.Repositories.Get(ctx, im.owner, im.repo)
+	if err != nil {
+		return fmt.Errorf("failed to look up the default branch: %v", err)
+	}
+	base, _, err := im.client.Git.GetRef(ctx, im.owner, im.repo, "heads/"+repository.GetDefaultBranch())
+	if err != nil {
+		return fmt.Errorf("failed to look up branch %s, which needs at least one commit: %v", repository.GetDefaultBranch(), err)
+	}
+	log.Printf("Creating branch %s for attachments from %s", im.attachmentsBranch, repository.GetDefaultBranch())
+	return im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.client.Git.CreateRef(ctx, im.owner, im.repo, &github.Reference{
+			Ref:    github.Ptr("refs/heads/" + im.attachmentsBranch),
+			Object: &github.GitObject{SHA: base.Object.SHA},
+		})
+		return resp, err
+	})
+}
+
+// copyAttachment downloads one attachment with the token and commits it to
+// the attachments branch under a name derived from its content, so that the
+// same file is only stored once. It returns the URL of the copy.
+func (im *importer) copyAttachment(ctx context.Context, source string) (string, error) {
+	data, contentType, err := im.downloadAttachment(ctx, source)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:8]) + attachmentExtension(source, contentType)
+	filePath := strings.Trim(path.Join(im.attachmentsPath, name), "/")
+	newURL := fmt.Sprintf("%s/%s/raw/%s/%s", im.webURL, im.targetRepo(), im.attachmentsBranch, filePath)
+
+	_, _, resp, err := im.client.Repositories.GetContents(ctx, im.owner, im.repo, filePath, &github.RepositoryContentGetOptions{Ref: im.attachmentsBranch})
+	if err == nil {
+		infof("Attachment %s was already copied to %s", source, filePath)
+		return newURL, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", err
+	}
+
+	err = im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.client.Repositories.CreateFile(ctx, im.owner, im.repo, filePath, &github.RepositoryContentFileOptions{
+			Message: github.Ptr("Add migrated issue attachment " + name),
+			Content: data,
+			Branch:  github.Ptr(im.attachmentsBranch),
+		})
+		return resp, err
+	})
+	if err != nil {
+		return "", err
+	}
+	infof("Copied attachment %s to %s", source, filePath)
+	return newURL, nil
+}
+
+// downloadAttachment fetches an attachment. The token is sent to the
+// attachment's own host, since private attachments require it, but not to the
+// storage host GitHub redirects to.
+func (im *importer) downloadAttachment(ctx context.Context, source string) ([]byte, string, error) {
+	resp, err := im.getWithoutForwardingToken(ctx, source)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("download failed: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, "", fmt.Errorf("attachment is larger than %d MB", maxAttachmentSize>>20)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// maxAttachmentRedirects is how many redirects are followed when downloading
+// an attachment, the same limit net/http uses.
+const maxAttachmentRedirects = 10
+
+// getWithoutForwardingToken GETs source with the authenticated client and
+// follows redirects itself, sending requests to any other host without
+// credentials. Leaving redirects to the client is not enough: its transport
+// sets the Authorization header on every request, including each hop.
+func (im *importer) getWithoutForwardingToken(ctx context.Context, source string) (*http.Response, error) {
+	stopRedirects := func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	authenticated := *im.client.Client()
+	authenticated.CheckRedirect = stopRedirects
+	anonymous := &http.Client{CheckRedirect: stopRedirects}
+
+	target, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	host := target.Host
+	for range maxAttachmentRedirects + 1 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		client := anonymous
+		if target.Host == host {
+			client = &authenticated
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return resp, nil
+		}
+		next, err := resp.Location()
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		target = next
+	}
+	return nil, fmt.Errorf("stopped after %d redirects", maxAttachmentRedirects)
+}
+
+// attachmentExtension returns the file extension for an attachment, taken
+// from its URL or else from its content type.
+func attachmentExtension(source, contentType string) string {
+	if u, err := url.Parse(source); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && len(ext) <= 6 {
+			return strings.ToLower(ext)
+		}
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestDownloadAttachmentKeepsTokenOnGitHub(t *testing.T) {
+	var storageAuth string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer storage.Close()
+
+	var githubAuth []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		githubAuth = append(githubAuth, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/user-attachments/assets/a":
+			http.Redirect(w, r, "/user-attachments/assets/b", http.StatusFound)
+		default:
+			http.Redirect(w, r, storage.URL+"/signed?sig=x", http.StatusFound)
+		}
+	}))
+	defer origin.Close()
+
+	client, err := newGitHubClient(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im := &importer{client: client}
+
+	data, contentType, err := im.downloadAttachment(context.Background(), origin.URL+"/user-attachments/assets/a")
+	if err != nil {
+		t.Fatalf("downloadAttachment: %v", err)
+	}
+	if string(data) != "png" || contentType != "image/png" {
+		t.Errorf("downloadAttachment = %q, %q, want %q, %q", data, contentType, "png", "image/png")
+	}
+	if len(githubAuth) != 2 || githubAuth[0] != "Bearer secret" || githubAuth[1] != "Bearer secret" {
+		t.Errorf("requests to the attachment host had Authorization %q, want the token on both", githubAuth)
+	}
+	if storageAuth != "" {
+		t.Errorf("storage host got Authorization %q, want none", storageAuth)
+	}
+}
+
+func TestDownloadAttachmentStopsRedirectLoops(t *testing.T) {
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}))
+	defer loop.Close()
+
+	client, err := newGitHubClient(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im := &importer{client: client}
+	if _, _, err := im.downloadAttachment(context.Background(), loop.URL+"/a"); err == nil {
+		t.Error("downloadAttachment followed a redirect loop without failing")
+	}
+}
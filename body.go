@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodyLength is the largest number of characters GitHub accepts in an issue
+// or comment body.
+const maxBodyLength = 65536
+
+// truncatedBodyMarker ends an issue body that was too long for GitHub; the
+// rest of the body is posted as the first comment on the new issue.
+const truncatedBodyMarker = "\n\n_This issue body exceeded GitHub's length limit. It continues in the comment below._"
+
+// bodySlack is left free in a truncated issue body so that it stays within
+// the limit when mentions and issue links in it are rewritten.
+const bodySlack = 1024
+
+// splitSourceBody returns the part of the source issue's body that goes into
+// the new issue body, and the overflow that has to be posted as a comment
+// because the whole body, once wrapped by issueBody, would be too long for
+// GitHub. The head of a truncated body ends with truncatedBodyMarker. The
+// split only depends on the source issue, so Phase 4 finds the same head.
+func (im *importer) splitSourceBody(issue Issue) (head, overflow string) {
+	if utf8.RuneCountInString(im.issueBody(issue, issue.Body)) <= maxBodyLength {
+		return issue.Body, ""
+	}
+	limit := maxBodyLength - bodySlack - utf8.RuneCountInString(im.issueBody(issue, truncatedBodyMarker))
+	cut := cutIndex(issue.Body, limit)
+	return strings.TrimRight(issue.Body[:cut], "\n") + truncatedBodyMarker, strings.TrimLeft(issue.Body[cut:], "\n")
+}
+
+// splitBody splits body into parts of at most limit characters each, cutting
+// at paragraph breaks where possible and at line breaks otherwise. A body
+// within the limit is returned as its only part.
+func splitBody(body string, limit int) []string {
+	var parts []string
+	for utf8.RuneCountInString(body) > limit {
+		cut := cutIndex(body, limit)
+		parts = append(parts, strings.TrimRight(body[:cut], "\n"))
+		body = strings.TrimLeft(body[cut:], "\n")
+	}
+	return append(parts, body)
+}
+
+// cutIndex returns the byte offset at which to cut body so that the part
+// before it holds at most limit characters. It prefers the last paragraph
+// break, then the last line break, and only cuts mid-line when neither exists.
+func cutIndex(body string, limit int) int {
+	end := len(body)
+	count := 0
+	for i := range body {
+		if count == limit {
+			end = i
+			break
+		}
+		count++
+	}
+
+	if i := strings.LastIndex(body[:end], "\n\n"); i > 0 {
+		return i
+	}
+	if i := strings.LastIndexByte(body[:end], '\n'); i > 0 {
+		return i
+	}
+	return end
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// oldNumberAnchorRegex matches the anchor added to issue bodies by
+// --embed-old-number.
+var oldNumberAnchorRegex = regexp.MustCompile(`<!-- original: #\d+ -->`)
+
+// cleanupResult counts what --delete-all did.
+type cleanupResult struct {
+	issuesClosed      int
+	labelsDeleted     int
+	milestonesDeleted int
+	failed            int
+}
+
+// isMigratedIssue reports whether an issue in the target repository carries
+// the tool's provenance marker: the --title-prefix, when one is given, or the
+// anchor added by --embed-old-number.
+func (im *importer) isMigratedIssue(issue *github.Issue) bool {
+	if im.titlePrefix != "" && strings.HasPrefix(issue.GetTitle(), im.titlePrefix) {
+		return true
+	}
+	return oldNumberAnchorRegex.MatchString(issue.GetBody())
+}
+
+// cleanup undoes a trial run in the target repository: it closes every open
+// issue carrying the tool's provenance marker, and, when createdReport is the
+// --report-out of that run, deletes the labels and milestones it created.
+// Issues without the marker are never touched.
+func (im *importer) cleanup(ctx context.Context, createdReport *report) (cleanupResult, error) {
+	var result cleanupResult
+
+	listOpts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	var migrated []*github.Issue
+	for {
+		issues, resp, err := im.issues.ListByRepo(ctx, im.owner, im.repo, listOpts)
+		if err != nil {
+			return result, fmt.Errorf("failed to list open issues: %v", err)
+		}
+		for _, issue := range issues {
+			if !issue.IsPullRequest() && im.isMigratedIssue(issue) {
+				migrated = append(migrated, issue)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.ListOptions.Page = resp.NextPage
+	}
+	log.Printf("Found %d open issues created by the tool.", len(migrated))
+
+	for _, issue := range migrated {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if im.dryRun {
+			infof("[dry-run] Would close issue #%d \"%s\"", issue.GetNumber(), issue.GetTitle())
+			continue
+		}
+		infof("Closing issue #%d \"%s\"", issue.GetNumber(), issue.GetTitle())
+		closeReq := &github.IssueRequest{State: github.Ptr("closed"), StateReason: github.Ptr("not_planned")}
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			_, resp, err := im.issues.Edit(ctx, im.owner, im.repo, issue.GetNumber(), closeReq)
+			return resp, err
+		})
+		if err != nil {
+			log.Printf("Failed to close issue #%d: %v\n", issue.GetNumber(), err)
+			result.failed++
+			continue
+		}
+		result.issuesClosed++
+	}
+
+	if createdReport == nil {
+		return result, nil
+	}
+
+	for _, name := range createdReport.LabelsCreated {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if im.dryRun {
+			infof("[dry-run] Would delete label [%s]", name)
+			continue
+		}
+		infof("Deleting label [%s]", name)
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			return im.issues.DeleteLabel(ctx, im.owner, im.repo, name)
+		})
+		if err != nil {
+			log.Printf("Failed to delete label [%s]: %v\n", name, err)
+			result.failed++
+			continue
+		}
+		result.labelsDeleted++
+	}
+
+	if len(createdReport.MilestonesCreated) == 0 {
+		return result, nil
+	}
+	milestoneNumbers, err := im.milestoneNumbers(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, title := range createdReport.MilestonesCreated {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		number, ok := milestoneNumbers[title]
+		if !ok {
+			infof("Milestone '%s' no longer exists, skipping it.", title)
+			continue
+		}
+		if im.dryRun {
+			infof("[dry-run] Would delete milestone '%s'", title)
+			continue
+		}
+		infof("Deleting milestone '%s'", title)
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			return im.issues.DeleteMilestone(ctx, im.owner, im.repo, number)
+		})
+		if err != nil {
+			log.Printf("Failed to delete milestone '%s': %v\n", title, err)
+			result.failed++
+			continue
+		}
+		result.milestonesDeleted++
+	}
+	return result, nil
+}
+
+// milestoneNumbers returns the numbers of all open and closed milestones in
+// the target repository, keyed by title.
+func (im *importer) milestoneNumbers(ctx context.Context) (map[string]int, error) {
+	numbers := make(map[string]int)
+	listOpts := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := im.issues.ListMilestones(ctx, im.owner, im.repo, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list milestones: %v", err)
+		}
+		for _, m := range milestones {
+			numbers[m.GetTitle()] = m.GetNumber()
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.ListOptions.Page = resp.NextPage
+	}
+	return numbers, nil
+}
+
+// readReport reads a report written by --report-out.
+func readReport(path string) (*report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// collapsible wraps content in a <details> block that GitHub renders collapsed,
+// with summary as the line that expands it.
+func collapsible(summary, content string) string {
+	return "<details>\n<summary>" + html.EscapeString(summary) + "</summary>\n\n" + content + "\n\n</details>"
+}
+
+// collapseEachComment renders every comment in its own collapsible block,
+// summarized by its author and posting date.
+func (im *importer) collapseEachComment(comments []Comment) string {
+	blocks := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		data := newCommentAuthorData(comment)
+		summary := data.Name
+		if data.Date != "" {
+			summary += " on " + data.Date
+		}
+		blocks = append(blocks, collapsible(summary, im.absolutizeURLs(strings.TrimRight(comment.Body, " \t\r\n"))))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// collapseByAuthor groups comments by author, in the order the authors first
+// commented, and renders every group in its own collapsible block summarized
+// by the author, the number of comments, and the dates they span. Inside a
+// block the comments keep their attribution lines and source order.
+func (im *importer) collapseByAuthor(comments []Comment) string {
+	var authors []string
+	byAuthor := make(map[string][]Comment)
+	for _, comment := range comments {
+		login := strings.ToLower(comment.Author.Login)
+		if _, ok := byAuthor[login]; !ok {
+			authors = append(authors, login)
+		}
+		byAuthor[login] = append(byAuthor[login], comment)
+	}
+
+	blocks := make([]string, 0, len(authors))
+	for _, login := range authors {
+		group := byAuthor[login]
+		summary := newCommentAuthorData(group[0]).Name
+		if len(group) == 1 {
+			summary += ", 1 comment"
+		} else {
+			summary += fmt.Sprintf(", %d comments", len(group))
+		}
+		first, last := newCommentAuthorData(group[0]).Date, newCommentAuthorData(group[len(group)-1]).Date
+		switch {
+		case first != "" && last != "" && first != last:
+			summary += fmt.Sprintf(" from %s to %s", first, last)
+		case first != "":
+			summary += " on " + first
+		}
+		blocks = append(blocks, collapsible(summary, im.joinComments(group)))
+	}
+	return strings.Join(blocks, "\n\n")
+}
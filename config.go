@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// loadConfig reads a --config file and returns the values it sets, keyed by
+// flag name. Files ending in .yaml or .yml are read as flat YAML, anything
+// else as a JSON object. A list sets a repeatable flag several times.
+func loadConfig(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAMLConfig(data)
+	default:
+		return parseJSONConfig(data)
+	}
+}
+
+// parseJSONConfig parses a JSON object whose values are strings, numbers,
+// booleans, or arrays of those.
+func parseJSONConfig(data []byte) (map[string][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw map[string]any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]string, len(raw))
+	for name, value := range raw {
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case string, json.Number, bool:
+				values[name] = append(values[name], fmt.Sprint(item))
+			default:
+				return nil, fmt.Errorf("option %q: expected a string, number, boolean, or a list of those", name)
+			}
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLConfig parses the flat subset of YAML a configuration needs:
+// "key: value" lines, lists written as "key: [a, b]" or as "- item" lines
+// below "key:", quoted or unquoted scalars, and # comments.
+func parseYAMLConfig(data []byte) (map[string][]string, error) {
+	values := make(map[string][]string)
+	listKey := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", i+1)
+			}
+			values[listKey] = append(values[listKey], yamlScalar(item))
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		key, value = strings.TrimSpace(key), stripYAMLComment(value)
+		listKey = ""
+		switch {
+		case value == "":
+			listKey = key
+		case strings.HasPrefix(value, "["):
+			inner, ok := strings.CutSuffix(value, "]")
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated list", i+1)
+			}
+			for _, item := range strings.Split(inner[1:], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values[key] = append(values[key], yamlScalar(item))
+				}
+			}
+		default:
+			values[key] = append(values[key], yamlScalar(value))
+		}
+	}
+	return values, nil
+}
+
+// yamlScalar strips a trailing comment from a YAML scalar and unquotes it if
+// it is quoted.
+func yamlScalar(value string) string {
+	value = stripYAMLComment(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// stripYAMLComment removes a # comment from the end of value. A # only starts
+// a comment at the beginning or after whitespace, and not inside a quoted
+// scalar, so "key: '#123' # note" keeps '#123'.
+func stripYAMLComment(value string) string {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[,", rune(value[i-1])) {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || value[i-1] == ' ' || value[i-1] == '\t' {
+				return strings.TrimSpace(value[:i])
+			}
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// applyConfig sets every flag named in values that was not given on the
+// command line, so that command-line flags override the configuration file.
+func applyConfig(values map[string][]string) error {
+	setOnCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		for _, value := range values[name] {
+			if err := flag.Set(name, value); err != nil {
+				return fmt.Errorf("option %q: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
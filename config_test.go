@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAMLConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want map[string][]string
+	}{
+		{"unquoted", "title-prefix: old", map[string][]string{"title-prefix": {"old"}}},
+		{"unquoted with comment", "title-prefix: old # trial", map[string][]string{"title-prefix": {"old"}}},
+		{"unquoted hash without space", "title-prefix: C#", map[string][]string{"title-prefix": {"C#"}}},
+		{"double quoted", `title-prefix: "[MIGRATED] "`, map[string][]string{"title-prefix": {"[MIGRATED] "}}},
+		{"double quoted with comment", `title-prefix: "[MIGRATED] " # trial`, map[string][]string{"title-prefix": {"[MIGRATED] "}}},
+		{"single quoted with comment", `title-prefix: '[MIGRATED] ' # trial`, map[string][]string{"title-prefix": {"[MIGRATED] "}}},
+		{"quoted hash", `title-prefix: "# old" # trial`, map[string][]string{"title-prefix": {"# old"}}},
+		{"number and boolean", "concurrency: 4\ndry-run: true", map[string][]string{"concurrency": {"4"}, "dry-run": {"true"}}},
+		{"flow list", `add-label: [migrated, "needs triage"]`, map[string][]string{"add-label": {"migrated", "needs triage"}}},
+		{"flow list with comment", `add-label: [migrated, 'a # b'] # labels`, map[string][]string{"add-label": {"migrated", "a # b"}}},
+		{
+			"block list",
+			"add-label: # labels\n  - migrated # first\n  - \"needs triage\" # second\n",
+			map[string][]string{"add-label": {"migrated", "needs triage"}},
+		},
+		{"comments and blank lines", "# settings\n\nrepo: o/r\n", map[string][]string{"repo": {"o/r"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAMLConfig([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("parseYAMLConfig: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAMLConfig(%q) = %q, want %q", tt.yaml, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLConfigErrors(t *testing.T) {
+	for _, yaml := range []string{
+		"- orphan",
+		"no separator",
+		"add-label: [migrated",
+	} {
+		if _, err := parseYAMLConfig([]byte(yaml)); err == nil {
+			t.Errorf("parseYAMLConfig(%q) succeeded, want an error", yaml)
+		}
+	}
+}
+
+func TestParseJSONConfig(t *testing.T) {
+	got, err := parseJSONConfig([]byte(`{"title-prefix": "[MIGRATED] ", "concurrency": 4, "dry-run": true, "add-label": ["a", "b"]}`))
+	if err != nil {
+		t.Fatalf("parseJSONConfig: %v", err)
+	}
+	want := map[string][]string{
+		"title-prefix": {"[MIGRATED] "},
+		"concurrency":  {"4"},
+		"dry-run":      {"true"},
+		"add-label":    {"a", "b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseJSONConfig = %q, want %q", got, want)
+	}
+
+	if _, err := parseJSONConfig([]byte(`{"add-label": [{"name": "a"}]}`)); err == nil {
+		t.Error("parseJSONConfig accepted an object value")
+	}
+}
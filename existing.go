@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// titleKey returns the key under which an issue title is compared against the
+// titles of existing issues.
+func (im *importer) titleKey(title string) string {
+	if im.ignoreTitleCase {
+		return strings.ToLower(title)
+	}
+	return title
+}
+
+// existingIssueTitles lists every open and closed issue in the target
+// repository and returns their numbers keyed by titleKey. When several issues
+// share a title, the oldest one is kept. Pull requests are left out.
+func (im *importer) existingIssueTitles(ctx context.Context) (map[string]int, error) {
+	numbersByTitle := make(map[string]int)
+	listOpts := &github.IssueListByRepoOptions{
+		State:       "all",
+		Sort:        "created",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		existingIssues, resp, err := im.issues.ListByRepo(ctx, im.owner, im.repo, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch existing issues: %v", err)
+		}
+		for _, issue := range existingIssues {
+			if issue.IsPullRequest() {
+				continue
+			}
+			key := im.titleKey(issue.GetTitle())
+			if _, ok := numbersByTitle[key]; !ok {
+				numbersByTitle[key] = issue.GetNumber()
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.ListOptions.Page = resp.NextPage
+	}
+	return numbersByTitle, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v73/github"
+)
+
+func TestSkipExistingTitles(t *testing.T) {
+	existing := [][]*github.Issue{
+		{
+			{Number: github.Ptr(7), Title: github.Ptr("Crash on start")},
+			{Number: github.Ptr(8), Title: github.Ptr("Add docs"), PullRequestLinks: &github.PullRequestLinks{}},
+		},
+		{
+			{Number: github.Ptr(9), Title: github.Ptr("Crash on start")},
+		},
+	}
+	issues := []Issue{
+		{Number: 1, Title: "Crash on start"},
+		{Number: 2, Title: "crash on start"},
+		{Number: 3, Title: "Add docs"},
+	}
+	tests := []struct {
+		name            string
+		ignoreTitleCase bool
+		wantMapping     map[int]int
+		wantCreated     int
+	}{
+		{
+			name:        "exact titles",
+			wantMapping: map[int]int{1: 7, 2: 10, 3: 11},
+			wantCreated: 2,
+		},
+		{
+			name:            "case-insensitive titles",
+			ignoreTitleCase: true,
+			wantMapping:     map[int]int{1: 7, 2: 7, 3: 10},
+			wantCreated:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeIssues{issuePages: existing, nextNumber: 10}
+			im := newTestImporter(f)
+			im.skipExistingTitles = true
+			im.ignoreTitleCase = tt.ignoreTitleCase
+
+			mapping, err := im.createIssueAndComment(context.Background(), issues, nil, map[int]int{})
+			if err != nil {
+				t.Fatalf("createIssueAndComment: %v", err)
+			}
+			if !maps.Equal(mapping, tt.wantMapping) {
+				t.Errorf("mapping %v, want %v", mapping, tt.wantMapping)
+			}
+			if len(f.created) != tt.wantCreated {
+				t.Errorf("created %d issues, want %d", len(f.created), tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestSkipExistingTitlesLeavesExistingIssueAlone(t *testing.T) {
+	f := &fakeIssues{
+		issuePages: [][]*github.Issue{{{Number: github.Ptr(7), Title: github.Ptr("Crash on start"), Body: github.Ptr("original text")}}},
+		nextNumber: 10,
+	}
+	im := newTestImporter(f)
+	im.skipExistingTitles = true
+	issues := []Issue{
+		{Number: 1, Title: "Crash on start", Body: "see #2", Parent: &IssueRef{Number: 2}},
+		{Number: 2, Title: "Other", Body: "duplicate of #1"},
+	}
+
+	ctx := context.Background()
+	mapping, err := im.createIssueAndComment(ctx, issues, nil, map[int]int{})
+	if err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if want := map[int]int{1: 7, 2: 10}; !maps.Equal(mapping, want) {
+		t.Fatalf("mapping %v, want %v", mapping, want)
+	}
+	if err := im.updateIssueLinks(ctx, issues, mapping); err != nil {
+		t.Fatalf("updateIssueLinks: %v", err)
+	}
+	if err := im.linkSubIssues(ctx, issues, mapping); err != nil {
+		t.Fatalf("linkSubIssues: %v", err)
+	}
+
+	if edits := f.edits[7]; len(edits) != 0 {
+		t.Errorf("existing issue #7 was edited: %v", edits)
+	}
+	if len(f.comments[7]) != 0 || len(f.editedComments) != 0 {
+		t.Errorf("comments on #7 %q and edited comments %v, want none", f.comments[7], f.editedComments)
+	}
+	if im.report.SubIssuesLinked != 0 || im.report.EditsFailed != 0 {
+		t.Errorf("%d sub-issue links and %d failed edits, want neither", im.report.SubIssuesLinked, im.report.EditsFailed)
+	}
+	edits := f.edits[10]
+	if len(edits) != 1 || !strings.Contains(edits[0].GetBody(), "duplicate of #7") {
+		t.Errorf("new issue #10 edits %v, want its link rewritten to #7", edits)
+	}
+}
+
+func TestSkipExistingTitlesLeavesFirstOfDuplicatesAlone(t *testing.T) {
+	f := &fakeIssues{nextNumber: 10}
+	im := newTestImporter(f)
+	im.skipExistingTitles = true
+	issues := []Issue{
+		{Number: 1, Title: "Flaky test", Body: "first report, see #3"},
+		{Number: 2, Title: "Flaky test", Body: "second report, see #3", Parent: &IssueRef{Number: 3}},
+		{Number: 3, Title: "Tracking"},
+	}
+
+	ctx := context.Background()
+	mapping, err := im.createIssueAndComment(ctx, issues, nil, map[int]int{})
+	if err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if want := map[int]int{1: 10, 2: 10, 3: 11}; !maps.Equal(mapping, want) {
+		t.Fatalf("mapping %v, want %v", mapping, want)
+	}
+	if err := im.updateIssueLinks(ctx, issues, mapping); err != nil {
+		t.Fatalf("updateIssueLinks: %v", err)
+	}
+	if err := im.linkSubIssues(ctx, issues, mapping); err != nil {
+		t.Fatalf("linkSubIssues: %v", err)
+	}
+
+	edits := f.edits[10]
+	if len(edits) != 1 {
+		t.Fatalf("issue #10 edits %v, want one", edits)
+	}
+	if body := edits[0].GetBody(); !strings.Contains(body, "first report, see #11") || strings.Contains(body, "second report") {
+		t.Errorf("issue #10 body rewritten to %q, want the first report with its link rewritten", body)
+	}
+	if im.report.SubIssuesLinked != 0 || im.report.EditsFailed != 0 {
+		t.Errorf("%d sub-issue links and %d failed edits, want neither", im.report.SubIssuesLinked, im.report.EditsFailed)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// exportIssues fetches every issue of owner/repo, open and closed, along with
+// its comments, in the same shape as the JSON written by gh issue list. Pull
+// requests are left out. The pinned state is not available from the REST API
+// and is always false.
+func exportIssues(ctx context.Context, client *github.Client, owner, repo string) ([]Issue, error) {
+	var issues []Issue
+	listOpts := &github.IssueListByRepoOptions{
+		State:       "all",
+		Sort:        "created",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		page, resp, err := client.Issues.ListByRepo(ctx, owner, repo, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list issues of %s/%s: %v", owner, repo, err)
+		}
+		for _, ghIssue := range page {
+			if ghIssue.IsPullRequest() {
+				continue
+			}
+			issue := exportedIssue(ghIssue)
+			if ghIssue.GetComments() > 0 {
+				issue.Comments, err = exportComments(ctx, client, owner, repo, ghIssue.GetNumber())
+				if err != nil {
+					return nil, err
+				}
+			}
+			issues = append(issues, issue)
+		}
+		log.Printf("Exported %d issues from %s/%s so far...", len(issues), owner, repo)
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.ListOptions.Page = resp.NextPage
+	}
+	return issues, nil
+}
+
+// exportComments fetches every comment on the given issue, oldest first.
+func exportComments(ctx context.Context, client *github.Client, owner, repo string, number int) ([]Comment, error) {
+	var comments []Comment
+	listOpts := &github.IssueListCommentsOptions{
+		Sort:        github.Ptr("created"),
+		Direction:   github.Ptr("asc"),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		page, resp, err := client.Issues.ListComments(ctx, owner, repo, number, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list comments of issue #%d: %v", number, err)
+		}
+		for _, ghComment := range page {
+			comments = append(comments, Comment{
+				Body:      ghComment.GetBody(),
+				Author:    User{Login: ghComment.GetUser().GetLogin()},
+				CreatedAt: exportedTime(ghComment.CreatedAt),
+				Reactions: exportedReactions(ghComment.Reactions),
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+	return comments, nil
+}
+
+// exportedIssue converts an issue returned by the REST API, without its
+// comments. States are upper-cased to match gh's output.
+func exportedIssue(ghIssue *github.Issue) Issue {
+	issue := Issue{
+		Number:      ghIssue.GetNumber(),
+		Title:       ghIssue.GetTitle(),
+		Body:        ghIssue.GetBody(),
+		Author:      User{Login: ghIssue.GetUser().GetLogin()},
+		CreatedAt:   exportedTime(ghIssue.CreatedAt),
+		UpdatedAt:   exportedTime(ghIssue.UpdatedAt),
+		State:       strings.ToUpper(ghIssue.GetState()),
+		Closed:      ghIssue.GetState() == "closed",
+		StateReason: strings.ToUpper(ghIssue.GetStateReason()),
+
+		Locked:           ghIssue.GetLocked(),
+		ActiveLockReason: ghIssue.GetActiveLockReason(),
+
+		Reactions: exportedReactions(ghIssue.Reactions),
+	}
+	for _, label := range ghIssue.Labels {
+		issue.Labels = append(issue.Labels, Label{
+			Name:        label.GetName(),
+			Color:       label.GetColor(),
+			Description: label.GetDescription(),
+		})
+	}
+	for _, assignee := range ghIssue.Assignees {
+		issue.Assignees = append(issue.Assignees, User{Login: assignee.GetLogin()})
+	}
+	if m := ghIssue.Milestone; m != nil {
+		issue.Milestone = &Milestone{
+			Title:       m.GetTitle(),
+			Description: m.GetDescription(),
+			State:       strings.ToUpper(m.GetState()),
+		}
+		if m.DueOn != nil {
+			dueOn := exportedTime(m.DueOn)
+			issue.Milestone.DueOn = &dueOn
+		}
+	}
+	return issue
+}
+
+// exportedTime formats t like gh does, or returns "" when it is unset.
+func exportedTime(t *github.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeIssues writes issues as a JSON array to path, or to standard output
+// when path is "-".
+func writeIssues(path string, issues []Issue) error {
+	if issues == nil {
+		issues = []Issue{}
+	}
+	data, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// fakeIssues is an in-memory issuesService. Labels, milestones, and existing
+// issues are served in the pages they are given in, new issues are numbered
+// from nextNumber on, and every mutation is appended to calls in order.
+type fakeIssues struct {
+	mu sync.Mutex
+
+	labelPages     [][]*github.Label
+	milestonePages [][]*github.Milestone
+	issuePages     [][]*github.Issue
+
+	// nextNumber is the number of the next created issue; 0 starts at 1.
+	nextNumber int
+	// failTitles and failComments make Create and CreateComment fail for
+	// issues with these titles and comments with these bodies.
+	failTitles   map[string]bool
+	failComments map[string]bool
+	// assignable lists the logins IsAssignee accepts, and assigneeErrors
+	// makes it fail with a 502 that many times for a login first.
+	assignable     map[string]bool
+	assigneeErrors map[string]int
+	// existingComments are the comments ListComments returns by issue
+	// number, as if posted before the run.
+	existingComments map[int][]*github.IssueComment
+
+	calls             []string
+	createdLabels     []*github.Label
+	editedLabels      map[string]*github.Label
+	createdMilestones []*github.Milestone
+	created           map[int]*github.IssueRequest
+	edits             map[int][]*github.IssueRequest
+	comments          map[int][]string
+	editedComments    map[int64]string
+}
+
+var _ issuesService = (*fakeIssues)(nil)
+
+func (f *fakeIssues) record(format string, args ...any) {
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+// page returns page (1-based, 0 meaning the first) of pages and the response
+// pointing at the next one.
+func page[T any](pages [][]T, number int) ([]T, *github.Response) {
+	number = max(number, 1)
+	resp := &github.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+	if number > len(pages) {
+		return nil, resp
+	}
+	if number < len(pages) {
+		resp.NextPage = number + 1
+	}
+	return pages[number-1], resp
+}
+
+func notFound() error {
+	return &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: "Not Found"}
+}
+
+func (f *fakeIssues) ListLabels(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.Label, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	labels, resp := page(f.labelPages, opts.Page)
+	return labels, resp, nil
+}
+
+func (f *fakeIssues) CreateLabel(ctx context.Context, owner, repo string, label *github.Label) (*github.Label, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("create label %s", label.GetName())
+	f.createdLabels = append(f.createdLabels, label)
+	return label, nil, nil
+}
+
+func (f *fakeIssues) EditLabel(ctx context.Context, owner, repo, name string, label *github.Label) (*github.Label, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("edit label %s", name)
+	if f.editedLabels == nil {
+		f.editedLabels = make(map[string]*github.Label)
+	}
+	f.editedLabels[name] = label
+	return label, nil, nil
+}
+
+func (f *fakeIssues) ListMilestones(ctx context.Context, owner, repo string, opts *github.MilestoneListOptions) ([]*github.Milestone, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	milestones, resp := page(f.milestonePages, opts.Page)
+	return milestones, resp, nil
+}
+
+func (f *fakeIssues) CreateMilestone(ctx context.Context, owner, repo string, milestone *github.Milestone) (*github.Milestone, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("create milestone %s", milestone.GetTitle())
+	created := *milestone
+	created.Number = github.Ptr(100 + len(f.createdMilestones))
+	f.createdMilestones = append(f.createdMilestones, &created)
+	return &created, nil, nil
+}
+
+func (f *fakeIssues) EditMilestone(ctx context.Context, owner, repo string, number int, milestone *github.Milestone) (*github.Milestone, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("edit milestone %d", number)
+	return milestone, nil, nil
+}
+
+func (f *fakeIssues) ListByRepo(ctx context.Context, owner, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	issues, resp := page(f.issuePages, opts.ListOptions.Page)
+	return issues, resp, nil
+}
+
+func (f *fakeIssues) Create(ctx context.Context, owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failTitles[issue.GetTitle()] {
+		return nil, nil, fmt.Errorf("failed to create %q", issue.GetTitle())
+	}
+	f.nextNumber = max(f.nextNumber, 1)
+	number := f.nextNumber
+	f.nextNumber++
+	f.record("create issue #%d", number)
+	if f.created == nil {
+		f.created = make(map[int]*github.IssueRequest)
+	}
+	f.created[number] = issue
+	return &github.Issue{Number: github.Ptr(number), Title: issue.Title, Body: issue.Body}, nil, nil
+}
+
+func (f *fakeIssues) Edit(ctx context.Context, owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if issue.State != nil {
+		f.record("edit issue #%d state=%s", number, issue.GetState())
+	} else {
+		f.record("edit issue #%d", number)
+	}
+	if f.edits == nil {
+		f.edits = make(map[int][]*github.IssueRequest)
+	}
+	f.edits[number] = append(f.edits[number], issue)
+	return &github.Issue{Number: github.Ptr(number)}, nil, nil
+}
+
+func (f *fakeIssues) Get(ctx context.Context, owner, repo string, number int) (*github.Issue, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, issues := range f.issuePages {
+		for _, issue := range issues {
+			if issue.GetNumber() == number {
+				return issue, nil, nil
+			}
+		}
+	}
+	return nil, nil, notFound()
+}
+
+func (f *fakeIssues) ListComments(ctx context.Context, owner, repo string, number int, opts *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	comments, resp := page([][]*github.IssueComment{f.existingComments[number]}, opts.ListOptions.Page)
+	return comments, resp, nil
+}
+
+func (f *fakeIssues) CreateComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failComments[comment.GetBody()] {
+		return nil, nil, fmt.Errorf("failed to comment on #%d", number)
+	}
+	f.record("comment on #%d", number)
+	if f.comments == nil {
+		f.comments = make(map[int][]string)
+	}
+	f.comments[number] = append(f.comments[number], comment.GetBody())
+	id := int64(number*1000 + len(f.comments[number]))
+	return &github.IssueComment{ID: github.Ptr(id), Body: comment.Body}, nil, nil
+}
+
+func (f *fakeIssues) EditComment(ctx context.Context, owner, repo string, commentID int64, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("edit comment %d", commentID)
+	if f.editedComments == nil {
+		f.editedComments = make(map[int64]string)
+	}
+	f.editedComments[commentID] = comment.GetBody()
+	return comment, nil, nil
+}
+
+func (f *fakeIssues) IsAssignee(ctx context.Context, owner, repo, user string) (bool, *github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.assigneeErrors[user] > 0 {
+		f.assigneeErrors[user]--
+		return false, nil, &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}, Message: "Bad Gateway"}
+	}
+	return f.assignable[user], nil, nil
+}
+
+func (f *fakeIssues) Lock(ctx context.Context, owner, repo string, number int, opts *github.LockIssueOptions) (*github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("lock issue #%d", number)
+	return nil, nil
+}
+
+func (f *fakeIssues) DeleteLabel(ctx context.Context, owner, repo, name string) (*github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("delete label %s", name)
+	return nil, nil
+}
+
+func (f *fakeIssues) DeleteMilestone(ctx context.Context, owner, repo string, number int) (*github.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("delete milestone %d", number)
+	return nil, nil
+}
+
+// newTestImporter returns an importer for the target repository o/r that
+// talks to f, with the defaults main sets.
+func newTestImporter(f *fakeIssues) *importer {
+	return &importer{
+		issues:             f,
+		owner:              "o",
+		repo:               "r",
+		report:             &report{},
+		postedComments:     make(map[int][]postedComment),
+		linkTargetsOnly:    make(map[int]bool),
+		previouslyImported: make(map[int]bool),
+		assignable:         make(map[string]bool),
+		concurrency:        1,
+		maxRetries:         1,
+		nextNumber:         1,
+		consolidatedHeader: defaultConsolidatedHeader,
+		createdIssues:      make(map[int]*github.Issue),
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// filterByState keeps the issues matching state, which is "open", "closed", or
+// "all".
+func filterByState(issues []Issue, state string) []Issue {
+	if state == "all" {
+		return issues
+	}
+	wantClosed := state == "closed"
+	return slices.DeleteFunc(issues, func(issue Issue) bool {
+		return issue.isClosed() != wantClosed
+	})
+}
+
+// filterSince keeps the issues created or updated at or after since. Issues
+// with neither time known are kept, since they cannot be told apart.
+func filterSince(issues []Issue, since time.Time) []Issue {
+	return slices.DeleteFunc(issues, func(issue Issue) bool {
+		known := false
+		for _, value := range []string{issue.CreatedAt, issue.UpdatedAt} {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				continue
+			}
+			if !t.Before(since) {
+				return false
+			}
+			known = true
+		}
+		return known
+	})
+}
+
+// dropComments removes the comments from every issue, so that no later phase
+// posts, plans, or scans them, and returns how many were removed.
+func dropComments(issues []Issue) int {
+	dropped := 0
+	for i := range issues {
+		dropped += len(issues[i].Comments)
+		issues[i].Comments = nil
+	}
+	return dropped
+}
+
+// dropCommentsBefore removes the comments posted before cutoff from every
+// issue and returns how many were removed. Comments without a known posting
+// time are kept.
+func dropCommentsBefore(issues []Issue, cutoff time.Time) int {
+	dropped := 0
+	for i := range issues {
+		before := len(issues[i].Comments)
+		issues[i].Comments = slices.DeleteFunc(issues[i].Comments, func(comment Comment) bool {
+			t, err := time.Parse(time.RFC3339, comment.CreatedAt)
+			return err == nil && t.Before(cutoff)
+		})
+		dropped += before - len(issues[i].Comments)
+	}
+	return dropped
+}
+
+// dropLabels removes the given labels from every issue, so that they are
+// neither created nor attached, and returns how many were removed. Label names
+// are compared exactly, or as normalizeLabels would fold them if ignoreCase is
+// set.
+func dropLabels(issues []Issue, labels []string, ignoreCase bool) int {
+	dropped := 0
+	for i := range issues {
+		before := len(issues[i].Labels)
+		issues[i].Labels = slices.DeleteFunc(issues[i].Labels, func(label Label) bool {
+			return containsLabel(labels, label.Name, ignoreCase)
+		})
+		dropped += before - len(issues[i].Labels)
+	}
+	return dropped
+}
+
+// filterByLabel keeps the issues carrying at least one of the given labels.
+// Label names are compared exactly, or as normalizeLabels would fold them if
+// ignoreCase is set.
+func filterByLabel(issues []Issue, labels []string, ignoreCase bool) []Issue {
+	return slices.DeleteFunc(issues, func(issue Issue) bool {
+		return !slices.ContainsFunc(issue.Labels, func(label Label) bool {
+			return containsLabel(labels, label.Name, ignoreCase)
+		})
+	})
+}
+
+// containsLabel reports whether name is one of labels. With ignoreCase, case
+// and surrounding whitespace are ignored, so that --filter-label and
+// --exclude-label match every variant --normalize-labels collapses.
+func containsLabel(labels []string, name string, ignoreCase bool) bool {
+	if !ignoreCase {
+		return slices.Contains(labels, name)
+	}
+	return slices.ContainsFunc(labels, func(label string) bool {
+		return strings.EqualFold(strings.TrimSpace(label), strings.TrimSpace(name))
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func issueNumbers(issues []Issue) []int {
+	var numbers []int
+	for _, issue := range issues {
+		numbers = append(numbers, issue.Number)
+	}
+	return numbers
+}
+
+func TestFilterByLabel(t *testing.T) {
+	newIssues := func() []Issue {
+		return []Issue{
+			{Number: 1, Labels: []Label{{Name: "bug"}}},
+			{Number: 2, Labels: []Label{{Name: "Bug"}}},
+			{Number: 3, Labels: []Label{{Name: "docs"}}},
+			{Number: 4},
+		}
+	}
+	tests := []struct {
+		name       string
+		labels     []string
+		ignoreCase bool
+		want       []int
+	}{
+		{"exact", []string{"Bug"}, false, []int{2}},
+		{"any of several", []string{"bug", "docs"}, false, []int{1, 3}},
+		{"normalized", []string{"Bug"}, true, []int{1, 2}},
+		{"normalized with spaces", []string{" BUG "}, true, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := issueNumbers(filterByLabel(newIssues(), tt.labels, tt.ignoreCase))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterByLabel(%q) kept %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropLabelsLeavesExcludedLabelsOut(t *testing.T) {
+	issues := []Issue{
+		{Number: 1, Title: "one", Labels: []Label{{Name: "bug"}, {Name: "wontfix-2019"}}},
+		{Number: 2, Title: "two", Labels: []Label{{Name: "Wontfix-2019"}}},
+		{Number: 3, Title: "three", Labels: []Label{{Name: "internal"}, {Name: "ui"}}},
+	}
+	if dropped := dropLabels(issues, []string{"wontfix-2019", "internal"}, false); dropped != 2 {
+		t.Errorf("dropped %d labels, want 2", dropped)
+	}
+
+	f := &fakeIssues{}
+	im := newTestImporter(f)
+	labels, _ := findLablesAndMilestones(issues)
+	if err := im.createLabels(context.Background(), labels); err != nil {
+		t.Fatalf("createLabels: %v", err)
+	}
+	created := slices.Sorted(slices.Values(labelNames(f.createdLabels)))
+	if want := []string{"Wontfix-2019", "bug", "ui"}; !slices.Equal(created, want) {
+		t.Errorf("created labels %v, want %v", created, want)
+	}
+
+	if _, err := im.createIssueAndComment(context.Background(), issues, nil, map[int]int{}); err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	want := map[int][]string{1: {"bug"}, 2: {"Wontfix-2019"}, 3: {"ui"}}
+	for number, labels := range want {
+		if got := f.created[number].GetLabels(); !slices.Equal(got, labels) {
+			t.Errorf("issue #%d created with labels %v, want %v", number, got, labels)
+		}
+	}
+}
+
+func TestDropLabelsIgnoresCaseWhenNormalizing(t *testing.T) {
+	issues := []Issue{
+		{Number: 1, Labels: []Label{{Name: "bug"}, {Name: "wontfix-2019"}}},
+		{Number: 2, Labels: []Label{{Name: "Wontfix-2019"}}},
+	}
+	if dropped := dropLabels(issues, []string{"WONTFIX-2019"}, true); dropped != 2 {
+		t.Errorf("dropped %d labels, want 2", dropped)
+	}
+	if len(issues[0].Labels) != 1 || issues[0].Labels[0].Name != "bug" || len(issues[1].Labels) != 0 {
+		t.Errorf("labels left %v and %v, want only bug", issues[0].Labels, issues[1].Labels)
+	}
+}
+
+func TestDropCommentsBefore(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	issues := []Issue{
+		{Number: 1, Comments: []Comment{
+			{Body: "old", CreatedAt: "2019-06-01T00:00:00Z"},
+			{Body: "recent", CreatedAt: "2024-03-01T00:00:00Z"},
+			{Body: "at the cutoff", CreatedAt: "2024-01-01T00:00:00Z"},
+			{Body: "undated"},
+		}},
+		{Number: 2, Comments: []Comment{
+			{Body: "older", CreatedAt: "2023-12-31T23:59:59Z"},
+			{Body: "offset", CreatedAt: "2024-01-01T00:30:00+01:00"},
+		}},
+		{Number: 3},
+	}
+
+	if dropped := dropCommentsBefore(issues, cutoff); dropped != 3 {
+		t.Errorf("dropped %d comments, want 3", dropped)
+	}
+	want := [][]string{{"recent", "at the cutoff", "undated"}, nil, nil}
+	for i, issue := range issues {
+		var bodies []string
+		for _, comment := range issue.Comments {
+			bodies = append(bodies, comment.Body)
+		}
+		if !slices.Equal(bodies, want[i]) {
+			t.Errorf("issue #%d kept comments %q, want %q", issue.Number, bodies, want[i])
+		}
+	}
+}
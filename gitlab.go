@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// gitlabIssue is an issue as returned by GitLab's issues API, optionally with
+// its notes attached under "notes".
+type gitlabIssue struct {
+	IID              int              `json:"iid"`
+	Title            string           `json:"title"`
+	Description      string           `json:"description"`
+	State            string           `json:"state"`
+	CreatedAt        string           `json:"created_at"`
+	UpdatedAt        string           `json:"updated_at"`
+	Author           gitlabUser       `json:"author"`
+	Assignees        []gitlabUser     `json:"assignees"`
+	Labels           []gitlabLabel    `json:"labels"`
+	Milestone        *gitlabMilestone `json:"milestone"`
+	DiscussionLocked bool             `json:"discussion_locked"`
+	Notes            []gitlabNote     `json:"notes"`
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+type gitlabMilestone struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	State       string `json:"state"`
+}
+
+type gitlabNote struct {
+	Body      string     `json:"body"`
+	Author    gitlabUser `json:"author"`
+	CreatedAt string     `json:"created_at"`
+	System    bool       `json:"system"`
+}
+
+// gitlabLabel is a label of a GitLab issue, exported either as a plain name
+// or, with with_labels_details=true, as an object with a color and a
+// description.
+type gitlabLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+func (l *gitlabLabel) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &l.Name); err == nil {
+		return nil
+	}
+	type plain gitlabLabel
+	return json.Unmarshal(data, (*plain)(l))
+}
+
+// toIssue maps a GitLab issue onto the fields gh exports, so the rest of the
+// pipeline handles both alike. The project-scoped iid becomes the issue
+// number, since that is the number #N references use. System notes, which
+// record events like label changes rather than discussion, are left out, and
+// the others are put in posting order, as GitLab lists the newest first.
+func (g gitlabIssue) toIssue() Issue {
+	issue := Issue{
+		Number:    g.IID,
+		Title:     g.Title,
+		Body:      g.Description,
+		Author:    g.Author.toUser(),
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+		State:     "OPEN",
+		Locked:    g.DiscussionLocked,
+	}
+	if strings.EqualFold(g.State, "closed") {
+		issue.State = "CLOSED"
+		issue.Closed = true
+	}
+	for _, label := range g.Labels {
+		issue.Labels = append(issue.Labels, Label{Name: label.Name, Color: label.Color, Description: label.Description})
+	}
+	for _, assignee := range g.Assignees {
+		issue.Assignees = append(issue.Assignees, assignee.toUser())
+	}
+	if g.Milestone != nil {
+		issue.Milestone = &Milestone{Title: g.Milestone.Title, Description: g.Milestone.Description, State: "open"}
+		if g.Milestone.DueDate != "" {
+			issue.Milestone.DueOn = &g.Milestone.DueDate
+		}
+		if strings.EqualFold(g.Milestone.State, "closed") {
+			issue.Milestone.State = "closed"
+		}
+	}
+	for _, note := range g.Notes {
+		if note.System {
+			continue
+		}
+		issue.Comments = append(issue.Comments, Comment{Body: note.Body, Author: note.Author.toUser(), CreatedAt: note.CreatedAt})
+	}
+	slices.SortStableFunc(issue.Comments, func(a, b Comment) int {
+		timeA, errA := time.Parse(time.RFC3339, a.CreatedAt)
+		timeB, errB := time.Parse(time.RFC3339, b.CreatedAt)
+		if errA != nil || errB != nil {
+			return 0
+		}
+		return timeA.Compare(timeB)
+	})
+	return issue
+}
+
+func (u gitlabUser) toUser() User {
+	return User{Login: u.Username, IsBot: u.Bot}
+}
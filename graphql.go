@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// graphQLEndpoint is resolved against the REST base URL. It maps
+// https://api.github.com/ to https://api.github.com/graphql and a GitHub
+// Enterprise Server's https://host/api/v3/ to https://host/api/graphql.
+const graphQLEndpoint = "../graphql"
+
+// maxPinnedIssues is the number of issues GitHub allows to be pinned in a
+// repository.
+const maxPinnedIssues = 3
+
+// graphQL runs a GraphQL query or mutation through the REST client, so it
+// shares the token, base URL, throttle, and rate-limit retries. When result is
+// not nil, the response's data is decoded into it.
+func (im *importer) graphQL(ctx context.Context, query string, variables map[string]any, result any) error {
+	var payload struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	err := im.withRetry(ctx, func() (*github.Response, error) {
+		req, err := im.client.NewRequest("POST", graphQLEndpoint, map[string]any{
+			"query":     query,
+			"variables": variables,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return im.client.Do(ctx, req, &payload)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(payload.Errors) > 0 {
+		messages := make([]string, 0, len(payload.Errors))
+		for _, e := range payload.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("graphql: %s", strings.Join(messages, "; "))
+	}
+	if result != nil {
+		return json.Unmarshal(payload.Data, result)
+	}
+	return nil
+}
+
+// pinIssue pins a newly created issue, skipping it once GitHub's limit of
+// pinned issues has been reached during this run. A failure is only returned
+// with --fail-fast.
+func (im *importer) pinIssue(ctx context.Context, issue *github.Issue) error {
+	// Reserve a pin slot up front so concurrent workers cannot exceed the
+	// limit; the slot is released again if pinning fails.
+	im.mu.Lock()
+	if im.pinnedCount >= maxPinnedIssues {
+		im.mu.Unlock()
+		infof("Skipping pin for issue #%d, GitHub allows at most %d pinned issues.", issue.GetNumber(), maxPinnedIssues)
+		return nil
+	}
+	im.pinnedCount++
+	im.mu.Unlock()
+
+	const mutation = `mutation($issueId: ID!) {
+  pinIssue(input: {issueId: $issueId}) {
+    issue { number }
+  }
+}`
+	infof("Pinning issue #%d", issue.GetNumber())
+	if err := im.graphQL(ctx, mutation, map[string]any{"issueId": issue.GetNodeID()}, nil); err != nil {
+		log.Printf("Failed to pin issue #%d: %v\n", issue.GetNumber(), err)
+		im.mu.Lock()
+		im.pinnedCount--
+		im.report.EditsFailed++
+		im.mu.Unlock()
+		if im.failFast {
+			return fmt.Errorf("failed to pin issue #%d: %v", issue.GetNumber(), err)
+		}
+		return nil
+	}
+	return nil
+}
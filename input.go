@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// decodeIssues streams the JSON array of issues in r, which is in the given
+// input format: "github" for the output of gh issue list, or "gitlab" for
+// GitLab's issues API. Every issue is passed to yield as soon as it is
+// decoded, so memory use does not grow with the size of the input beyond the
+// issues yield keeps. loadIssues keeps all of them.
+func decodeIssues(r io.Reader, format string, yield func(Issue)) error {
+	if format != "github" && format != "gitlab" {
+		return fmt.Errorf("unknown input format %q", format)
+	}
+
+	dec := json.NewDecoder(r)
+	if token, err := dec.Token(); err != nil {
+		return err
+	} else if token == nil {
+		// A null document decodes to no issues, as with json.Unmarshal.
+		return nil
+	} else if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a JSON array of issues, found %v", token)
+	}
+
+	for dec.More() {
+		if format == "gitlab" {
+			var issue gitlabIssue
+			if err := dec.Decode(&issue); err != nil {
+				return err
+			}
+			yield(issue.toIssue())
+			continue
+		}
+		var issue Issue
+		if err := dec.Decode(&issue); err != nil {
+			return err
+		}
+		yield(issue)
+	}
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// writeSyntheticIssues writes a JSON array of n issues, numbered from first
+// on, each with a body of bodySize bytes and two comments, and reports how
+// many issues it has written to written.
+func writeSyntheticIssues(w io.Writer, first, n, bodySize int, written *atomic.Int64) error {
+	body := strings.Repeat("x", bodySize)
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i := range n {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, `{"number":%d,"title":"issue %d","body":%q,"state":"OPEN","createdAt":"2024-01-01T00:00:00Z","labels":[{"name":"bug"}],"comments":[{"body":"one","author":{"login":"a"}},{"body":"two","author":{"login":"b"}}]}`, first+i, first+i, body)
+		if err != nil {
+			return err
+		}
+		if written != nil {
+			written.Add(1)
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+func TestDecodeIssuesStreamsLargeInput(t *testing.T) {
+	const n = 20000
+	r, w := io.Pipe()
+	var written atomic.Int64
+	go func() {
+		w.CloseWithError(writeSyntheticIssues(w, 1, n, 512, &written))
+	}()
+
+	decoded := 0
+	writtenAtFirstIssue := int64(-1)
+	err := decodeIssues(r, "github", func(issue Issue) {
+		if writtenAtFirstIssue < 0 {
+			writtenAtFirstIssue = written.Load()
+		}
+		decoded++
+		if issue.Number != decoded || len(issue.Comments) != 2 {
+			t.Fatalf("issue %d decoded as #%d with %d comments", decoded, issue.Number, len(issue.Comments))
+		}
+	})
+	if err != nil {
+		t.Fatalf("decodeIssues: %v", err)
+	}
+	if decoded != n {
+		t.Errorf("decoded %d issues, want %d", decoded, n)
+	}
+	if writtenAtFirstIssue >= n {
+		t.Errorf("the first issue was only yielded after all %d were written", n)
+	}
+}
+
+func TestLoadIssuesMergesLargeFiles(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, first := range []int{1, 5001} {
+		path := filepath.Join(dir, fmt.Sprintf("issues-%d.json", i))
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The files overlap in issues 5001 to 10000.
+		if err := writeSyntheticIssues(f, first, 10000, 256, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	captureLog(t)
+	issues, err := loadIssues(paths, "github")
+	if err != nil {
+		t.Fatalf("loadIssues: %v", err)
+	}
+	if len(issues) != 15000 {
+		t.Fatalf("loaded %d issues, want 15000", len(issues))
+	}
+	for i, issue := range issues {
+		if issue.Number != i+1 {
+			t.Fatalf("issue %d is #%d, want #%d", i, issue.Number, i+1)
+		}
+	}
+}
+
+func TestDecodeIssuesSmallInputs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"[]", 0},
+		{"null", 0},
+		{`[{"number":1,"title":"one"}]`, 1},
+	}
+	for _, tt := range tests {
+		decoded := 0
+		if err := decodeIssues(strings.NewReader(tt.input), "github", func(Issue) { decoded++ }); err != nil {
+			t.Errorf("decodeIssues(%s): %v", tt.input, err)
+		}
+		if decoded != tt.want {
+			t.Errorf("decodeIssues(%s) decoded %d issues, want %d", tt.input, decoded, tt.want)
+		}
+	}
+	for _, input := range []string{`{"number":1}`, `[{"number":1}`, `[{"number":"one"}]`} {
+		if err := decodeIssues(strings.NewReader(input), "github", func(Issue) {}); err == nil {
+			t.Errorf("decodeIssues(%s) succeeded, want an error", input)
+		}
+	}
+}
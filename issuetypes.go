@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// typeMapping maps a label onto the issue type, such as "Bug", given to new
+// issues carrying it.
+type typeMapping struct {
+	Label string
+	Type  string
+}
+
+// parseTypeMappings parses --type-from-label values of the form label=Type,
+// keeping their declared order.
+func parseTypeMappings(values []string) ([]typeMapping, error) {
+	mappings := make([]typeMapping, 0, len(values))
+	for _, value := range values {
+		label, typeName, ok := strings.Cut(value, "=")
+		label, typeName = strings.TrimSpace(label), strings.TrimSpace(typeName)
+		if !ok || label == "" || typeName == "" {
+			return nil, fmt.Errorf("invalid type mapping %q, expected label=Type", value)
+		}
+		mappings = append(mappings, typeMapping{Label: label, Type: typeName})
+	}
+	return mappings, nil
+}
+
+// issueType returns the issue type for a source issue and the label it was
+// taken from, using the first mapping in declared order whose label the issue
+// carries, or empty strings when none matches.
+func (im *importer) issueType(issue Issue) (typeName, label string) {
+	for _, m := range im.typeMappings {
+		for _, l := range issue.Labels {
+			if l.Name == m.Label {
+				return m.Type, m.Label
+			}
+		}
+	}
+	return "", ""
+}
+
+// loadIssueTypes looks up the node IDs of the issue types available in the
+// target repository, and fails if a mapped type does not exist there.
+func (im *importer) loadIssueTypes(ctx context.Context) error {
+	const query = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    issueTypes(first: 100) {
+      nodes { id name }
+    }
+  }
+}`
+	var result struct {
+		Repository struct {
+			IssueTypes struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"nodes"`
+			} `json:"issueTypes"`
+		} `json:"repository"`
+	}
+	if err := im.graphQL(ctx, query, map[string]any{"owner": im.owner, "name": im.repo}, &result); err != nil {
+		return fmt.Errorf("failed to list issue types: %v", err)
+	}
+
+	im.issueTypeIDs = make(map[string]string)
+	for _, node := range result.Repository.IssueTypes.Nodes {
+		im.issueTypeIDs[strings.ToLower(node.Name)] = node.ID
+	}
+	for _, m := range im.typeMappings {
+		if _, ok := im.issueTypeIDs[strings.ToLower(m.Type)]; !ok {
+			return fmt.Errorf("issue type %q is not available in %s/%s", m.Type, im.owner, im.repo)
+		}
+	}
+	log.Printf("Found %d issue types in the target repository.", len(im.issueTypeIDs))
+	return nil
+}
+
+// setIssueType gives a newly created issue the named issue type. A failure is
+// only returned with --fail-fast.
+func (im *importer) setIssueType(ctx context.Context, issue *github.Issue, typeName string) error {
+	const mutation = `mutation($issueId: ID!, $issueTypeId: ID!) {
+  updateIssueIssueType(input: {issueId: $issueId, issueTypeId: $issueTypeId}) {
+    issue { number }
+  }
+}`
+	infof("Setting type of issue #%d to %s", issue.GetNumber(), typeName)
+	variables := map[string]any{"issueId": issue.GetNodeID(), "issueTypeId": im.issueTypeIDs[strings.ToLower(typeName)]}
+	if err := im.graphQL(ctx, mutation, variables, nil); err != nil {
+		log.Printf("Failed to set type of issue #%d to %s: %v\n", issue.GetNumber(), typeName, err)
+		im.mu.Lock()
+		im.report.EditsFailed++
+		im.mu.Unlock()
+		if im.failFast {
+			return fmt.Errorf("failed to set type of issue #%d to %s: %v", issue.GetNumber(), typeName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// defaultLabelColor is used for labels whose source color is missing or
+// invalid. It matches the gray GitHub picks for new labels.
+const defaultLabelColor = "ededed"
+
+var labelColorRegex = regexp.MustCompile(`^[0-9a-f]{6}$`)
+
+// githubDefaultLabels are the labels GitHub creates in every new repository.
+// With --reconcile-defaults, their color and description are updated to match
+// the source even without --update-labels.
+var githubDefaultLabels = []string{
+	"bug",
+	"documentation",
+	"duplicate",
+	"enhancement",
+	"good first issue",
+	"help wanted",
+	"invalid",
+	"question",
+	"wontfix",
+}
+
+// maxLabelNameLength is the longest label name, in characters, that GitHub
+// accepts.
+const maxLabelNameLength = 50
+
+// sanitizeLabelName returns name in a form GitHub accepts as a label name:
+// control characters are dropped, runs of whitespace are collapsed into one
+// space, surrounding whitespace is trimmed, and the result is cut to
+// maxLabelNameLength characters.
+func sanitizeLabelName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name)
+	cleaned = strings.Join(strings.Fields(cleaned), " ")
+	if runes := []rune(cleaned); len(runes) > maxLabelNameLength {
+		cleaned = strings.TrimSpace(string(runes[:maxLabelNameLength]))
+	}
+	return cleaned
+}
+
+// sanitizeLabels applies sanitizeLabelName to the labels of every issue,
+// logging each name that had to change once. Labels that end up with the same
+// name on one issue are only kept once. It runs at the start of Phase 1, so
+// the created labels and the labels attached to issues stay consistent.
+func sanitizeLabels(issues []Issue) {
+	changed := make(map[string]bool)
+	for i := range issues {
+		labels := make([]Label, 0, len(issues[i].Labels))
+		seen := make(map[string]bool)
+		for _, label := range issues[i].Labels {
+			if sanitized := sanitizeLabelName(label.Name); sanitized != label.Name {
+				if !changed[label.Name] {
+					log.Printf("Warning: label %q is not a valid GitHub label name, using %q instead.", label.Name, sanitized)
+					changed[label.Name] = true
+				}
+				label.Name = sanitized
+			}
+			if seen[label.Name] {
+				continue
+			}
+			seen[label.Name] = true
+			labels = append(labels, label)
+		}
+		issues[i].Labels = labels
+	}
+}
+
+// normalizeLabelColor strips a leading "#" and lowercases color, the form
+// GitHub's API expects. Anything that is not six hex digits afterwards falls
+// back to defaultLabelColor with a warning.
+func normalizeLabelColor(name, color string) string {
+	normalized := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if !labelColorRegex.MatchString(normalized) {
+		log.Printf("Warning: label [%s] has invalid color %q, using %s instead.", name, color, defaultLabelColor)
+		return defaultLabelColor
+	}
+	return normalized
+}
+
+// applyLabelMap renames the labels of every issue according to labelMap,
+// dropping labels mapped to the empty string. Labels that collapse into the
+// same name on one issue are only kept once. Running it before Phase 1 means
+// both the created labels and the labels attached to issues use the new names.
+func applyLabelMap(issues []Issue, labelMap map[string]string) {
+	if len(labelMap) == 0 {
+		return
+	}
+
+	for i := range issues {
+		labels := make([]Label, 0, len(issues[i].Labels))
+		seen := make(map[string]bool)
+		for _, label := range issues[i].Labels {
+			if newName, ok := labelMap[label.Name]; ok {
+				if newName == "" {
+					continue
+				}
+				label.Name = newName
+			}
+			if seen[label.Name] {
+				continue
+			}
+			seen[label.Name] = true
+			labels = append(labels, label)
+		}
+		issues[i].Labels = labels
+	}
+	log.Printf("Applied %d label mappings.\n", len(labelMap))
+}
+
+// normalizeLabels lowercases and trims the label names of every issue so
+// that case variants like "Bug", "bug", and "BUG" collapse into one label. The
+// color and description of the first variant encountered are kept for the
+// canonical label, and an issue carrying several variants keeps only one.
+func normalizeLabels(issues []Issue) {
+	canonical := make(map[string]Label)
+	variants := make(map[string]map[string]bool)
+	for i := range issues {
+		labels := make([]Label, 0, len(issues[i].Labels))
+		seen := make(map[string]bool)
+		for _, label := range issues[i].Labels {
+			name := strings.ToLower(strings.TrimSpace(label.Name))
+			first, ok := canonical[name]
+			if !ok {
+				first = label
+				first.Name = name
+				canonical[name] = first
+				variants[name] = make(map[string]bool)
+			}
+			variants[name][label.Name] = true
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			labels = append(labels, first)
+		}
+		issues[i].Labels = labels
+	}
+
+	merged := 0
+	for name, spellings := range variants {
+		if len(spellings) > 1 || !spellings[name] {
+			infof("Normalized label %s to [%s]", strings.Join(slices.Sorted(maps.Keys(spellings)), ", "), name)
+			merged++
+		}
+	}
+	log.Printf("Normalized the names of %d labels.\n", merged)
+}
+
+// existingLabels returns the labels of the target repository keyed by their
+// lowercased name, since GitHub treats label names that differ only in case
+// as the same label.
+func (im *importer) existingLabels(ctx context.Context) (map[string]*github.Label, error) {
+	existingLabelsByName := make(map[string]*github.Label)
+	listOpts := &github.ListOptions{PerPage: 100}
+	for {
+		existingLabels, resp, err := im.issues.ListLabels(ctx, im.owner, im.repo, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch existing labels: %v", err)
+		}
+		for _, label := range existingLabels {
+			existingLabelsByName[strings.ToLower(label.GetName())] = label
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+	return existingLabelsByName, nil
+}
+
+// updateLabel edits an existing target label whose color or description differ
+// from the source label, logging exactly which attributes changed. A failure
+// is only returned with --fail-fast.
+func (im *importer) updateLabel(ctx context.Context, existing *github.Label, label Label) error {
+	var changes []string
+	edit := &github.Label{}
+	if existing.GetColor() != label.Color {
+		changes = append(changes, fmt.Sprintf("color %q -> %q", existing.GetColor(), label.Color))
+		edit.Color = github.Ptr(label.Color)
+	}
+	if existing.GetDescription() != label.Description {
+		changes = append(changes, fmt.Sprintf("description %q -> %q", existing.GetDescription(), label.Description))
+		edit.Description = github.Ptr(label.Description)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if im.dryRun {
+		infof("[dry-run] Would update label [%s]: %s", label.Name, strings.Join(changes, ", "))
+		return nil
+	}
+	infof("Updating label [%s]: %s", label.Name, strings.Join(changes, ", "))
+	err := im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.issues.EditLabel(ctx, im.owner, im.repo, existing.GetName(), edit)
+		return resp, err
+	})
+	if err != nil {
+		log.Printf("Warning: failed to update label [%s]: %v\n", label.Name, err)
+		im.report.EditsFailed++
+		if im.failFast {
+			return fmt.Errorf("failed to update label [%s]: %v", label.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v73/github"
+)
+
+func labelNames(labels []*github.Label) []string {
+	var names []string
+	for _, label := range labels {
+		names = append(names, label.GetName())
+	}
+	return names
+}
+
+func TestCreateLabelsSkipsExistingAndDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		issues   []Issue
+		want     []string
+	}{
+		{
+			name:   "empty target",
+			issues: []Issue{{Labels: []Label{{Name: "bug"}, {Name: "docs"}}}},
+			want:   []string{"bug", "docs"},
+		},
+		{
+			name:     "label used by several issues is created once",
+			existing: []string{"wontfix"},
+			issues: []Issue{
+				{Labels: []Label{{Name: "bug"}}},
+				{Labels: []Label{{Name: "bug"}, {Name: "ui"}}},
+			},
+			want: []string{"bug", "ui"},
+		},
+		{
+			name:     "existing labels are not created again",
+			existing: []string{"bug", "ui"},
+			issues:   []Issue{{Labels: []Label{{Name: "bug"}, {Name: "ui"}, {Name: "new"}}}},
+			want:     []string{"new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var existing []*github.Label
+			for _, name := range tt.existing {
+				existing = append(existing, &github.Label{Name: github.Ptr(name)})
+			}
+			f := &fakeIssues{labelPages: [][]*github.Label{existing}}
+			im := newTestImporter(f)
+
+			labels, _ := findLablesAndMilestones(tt.issues)
+			if err := im.createLabels(context.Background(), labels); err != nil {
+				t.Fatalf("createLabels: %v", err)
+			}
+			if got := labelNames(f.createdLabels); !slices.Equal(got, tt.want) {
+				t.Errorf("created %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateLabelsReadsEveryPage(t *testing.T) {
+	f := &fakeIssues{labelPages: [][]*github.Label{
+		{{Name: github.Ptr("bug")}, {Name: github.Ptr("docs")}},
+		{{Name: github.Ptr("ui")}},
+	}}
+	im := newTestImporter(f)
+
+	labels := map[string]Label{"bug": {Name: "bug"}, "ui": {Name: "ui"}, "new": {Name: "new"}}
+	if err := im.createLabels(context.Background(), labels); err != nil {
+		t.Fatalf("createLabels: %v", err)
+	}
+	if got := labelNames(f.createdLabels); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("created %v, want only [new]; the label on page 2 was created again", got)
+	}
+}
+
+func TestNormalizeLabelColor(t *testing.T) {
+	tests := []struct {
+		color string
+		want  string
+	}{
+		{"#FFAABB", "ffaabb"},
+		{"ffaabb", "ffaabb"},
+		{" #0e8a16 ", "0e8a16"},
+		{"", defaultLabelColor},
+		{"red", defaultLabelColor},
+		{"#ffaabbcc", defaultLabelColor},
+		{"#xyzxyz", defaultLabelColor},
+	}
+	for _, tt := range tests {
+		if got := normalizeLabelColor("label", tt.color); got != tt.want {
+			t.Errorf("normalizeLabelColor(%q) = %q, want %q", tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	long := strings.Repeat("a", maxLabelNameLength-1) + " bbb"
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"bug", "bug"},
+		{"  needs   triage ", "needs triage"},
+		{"line\nbreak\ttab", "line break tab"},
+		{"bell\acontrol\x00", "bellcontrol"},
+		{"área: ñandú 🐛", "área: ñandú 🐛"},
+		{strings.Repeat("x", 60), strings.Repeat("x", maxLabelNameLength)},
+		{strings.Repeat("é", 60), strings.Repeat("é", maxLabelNameLength)},
+		{long, strings.Repeat("a", maxLabelNameLength-1)},
+	}
+	for _, tt := range tests {
+		got := sanitizeLabelName(tt.name)
+		if got != tt.want {
+			t.Errorf("sanitizeLabelName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > maxLabelNameLength {
+			t.Errorf("sanitizeLabelName(%q) is %d characters long", tt.name, n)
+		}
+	}
+}
+
+func TestSanitizeLabelsKeepsCollidingNamesOnce(t *testing.T) {
+	captureLog(t)
+	issues := []Issue{{Labels: []Label{{Name: "needs  triage"}, {Name: "needs triage"}, {Name: "bug\n"}}}}
+	sanitizeLabels(issues)
+	var got []string
+	for _, label := range issues[0].Labels {
+		got = append(got, label.Name)
+	}
+	if want := []string{"needs triage", "bug"}; !slices.Equal(got, want) {
+		t.Errorf("sanitized labels %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeLabelsCollapsesCaseVariants(t *testing.T) {
+	captureLog(t)
+	issues := []Issue{
+		{Number: 1, Labels: []Label{{Name: "Bug", Color: "ff0000", Description: "first"}}},
+		{Number: 2, Labels: []Label{{Name: "bug", Color: "00ff00"}, {Name: " BUG "}, {Name: "UI"}}},
+		{Number: 3, Labels: []Label{{Name: "BUG"}}},
+	}
+	normalizeLabels(issues)
+
+	want := [][]string{{"bug"}, {"bug", "ui"}, {"bug"}}
+	for i, issue := range issues {
+		var names []string
+		for _, label := range issue.Labels {
+			names = append(names, label.Name)
+		}
+		if !slices.Equal(names, want[i]) {
+			t.Errorf("issue #%d labels %v, want %v", issue.Number, names, want[i])
+		}
+	}
+
+	labels, _ := findLablesAndMilestones(issues)
+	if len(labels) != 2 {
+		t.Fatalf("collected labels %v, want bug and ui", labels)
+	}
+	if bug := labels["bug"]; bug.Color != "ff0000" || bug.Description != "first" {
+		t.Errorf("bug label %+v, want the color and description of the first variant", bug)
+	}
+}
+
+func TestIssueLabelsAreSorted(t *testing.T) {
+	im := newTestImporter(&fakeIssues{})
+	im.addLabels = []string{"migrated", "area: ui"}
+	issue := Issue{Labels: []Label{{Name: "zeta"}, {Name: "Bug"}, {Name: "alpha"}, {Name: "migrated"}}}
+
+	want := []string{"Bug", "alpha", "area: ui", "migrated", "zeta"}
+	if got := im.issueLabels(issue); !slices.Equal(got, want) {
+		t.Errorf("issueLabels = %v, want %v", got, want)
+	}
+
+	f := &fakeIssues{}
+	im = newTestImporter(f)
+	im.addLabels = []string{"migrated", "area: ui"}
+	if _, err := im.createIssueAndComment(context.Background(), []Issue{{Number: 1, Title: "one", Labels: issue.Labels}}, nil, map[int]int{}); err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if got := f.created[1].GetLabels(); !slices.Equal(got, want) {
+		t.Errorf("issue created with labels %v, want %v", got, want)
+	}
+}
+
+func TestCreateLabelsMatchesExistingLabelsIgnoringCase(t *testing.T) {
+	f := &fakeIssues{labelPages: [][]*github.Label{{{Name: github.Ptr("Bug"), Color: github.Ptr("d73a4a")}}}}
+	im := newTestImporter(f)
+	labels := map[string]Label{
+		"bug":  {Name: "bug", Color: "ff0000"},
+		"docs": {Name: "docs", Color: "0075ca"},
+	}
+	if err := im.createLabels(context.Background(), labels); err != nil {
+		t.Fatalf("createLabels: %v", err)
+	}
+	if got, want := labelNames(f.createdLabels), []string{"docs"}; !slices.Equal(got, want) {
+		t.Errorf("created labels %v, want %v", got, want)
+	}
+	if want := []string{"bug"}; !slices.Equal(im.report.LabelsSkipped, want) {
+		t.Errorf("skipped labels %v, want %v", im.report.LabelsSkipped, want)
+	}
+
+	im.updateLabels = true
+	if err := im.createLabels(context.Background(), labels); err != nil {
+		t.Fatalf("createLabels with --update-labels: %v", err)
+	}
+	if edit, ok := f.editedLabels["Bug"]; !ok || edit.GetColor() != "ff0000" {
+		t.Errorf("edited labels %v, want Bug recolored to ff0000", f.editedLabels)
+	}
+}
+
+func TestCreateLabelsReconcilesRecasedDefaults(t *testing.T) {
+	f := &fakeIssues{labelPages: [][]*github.Label{{
+		{Name: github.Ptr("Bug"), Color: github.Ptr("d73a4a")},
+		{Name: github.Ptr("Enhancement"), Color: github.Ptr("a2eeef")},
+		{Name: github.Ptr("Team"), Color: github.Ptr("000000")},
+	}}}
+	im := newTestImporter(f)
+	im.reconcileDefaults = true
+	labels := map[string]Label{
+		"bug":         {Name: "bug", Color: "ff0000"},
+		"enhancement": {Name: "enhancement", Color: "00ff00"},
+		"team":        {Name: "team", Color: "ffffff"},
+	}
+	if err := im.createLabels(context.Background(), labels); err != nil {
+		t.Fatalf("createLabels: %v", err)
+	}
+	if len(f.createdLabels) != 0 {
+		t.Errorf("created labels %v, want none", labelNames(f.createdLabels))
+	}
+	if got, want := slices.Sorted(maps.Keys(f.editedLabels)), []string{"Bug", "Enhancement"}; !slices.Equal(got, want) {
+		t.Errorf("edited labels %v, want %v", got, want)
+	}
+}
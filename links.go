@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// issueLinkRegex matches a #N issue reference. The digits must end at a word
+// boundary so hex colors like #1234ab are not mistaken for references, and the
+// # must not directly follow a word character or & (as in repo#12 or &#39;).
+var issueLinkRegex = regexp.MustCompile(`(^|[^\w&])#(\d+)\b`)
+
+// qualifiedIssueLinkRegex matches an owner/repo#N issue reference. The owner
+// must not directly follow a word character, dot, or hyphen so that it is not
+// matched in the middle of a longer name.
+var qualifiedIssueLinkRegex = regexp.MustCompile(`(^|[^\w.-])([\w.-]+/[\w.-]+)#(\d+)\b`)
+
+// codeSpanRegex matches fenced code blocks (an unterminated fence runs to the
+// end of the text) and inline code spans, whose contents are literal text.
+var codeSpanRegex = regexp.MustCompile("(?s)```.*?(?:```|$)|`[^`]+`")
+
+// linkRewrite records a single #N reference that was remapped.
+type linkRewrite struct {
+	oldNum int
+	newNum int
+}
+
+// rewriteIssueLinks replaces every #N reference to a migrated source issue in
+// text with the issue's new number. When sourceRepo is set, sourceRepo#N
+// references are rewritten as well, to targetRepo and the new number;
+// references to any other repository are kept as they are. References to
+// issues that are not in oldToNewIssueNumbers, and anything inside code blocks
+// or inline code, are left untouched; the numbers of the former are returned
+// as unresolved. It is shared by issue bodies and comments.
+func rewriteIssueLinks(text string, oldToNewIssueNumbers map[int]int, sourceRepo, targetRepo string) (string, []linkRewrite, []int) {
+	var (
+		rewrites   []linkRewrite
+		unresolved []int
+	)
+	rewriteNumber := func(match string, rewriteRef func(prefix string, newNum int) string) string {
+		hash := strings.LastIndexByte(match, '#')
+		oldNum, _ := strconv.Atoi(match[hash+1:])
+
+		if newNum, found := oldToNewIssueNumbers[oldNum]; found {
+			rewrites = append(rewrites, linkRewrite{oldNum: oldNum, newNum: newNum})
+			return rewriteRef(match[:hash], newNum)
+		}
+		unresolved = append(unresolved, oldNum)
+		return match
+	}
+	rewriteBare := func(prose string) string {
+		return issueLinkRegex.ReplaceAllStringFunc(prose, func(match string) string {
+			return rewriteNumber(match, func(prefix string, newNum int) string {
+				return fmt.Sprintf("%s#%d", prefix, newNum)
+			})
+		})
+	}
+
+	updated := rewriteOutsideCode(text, func(prose string) string {
+		// Qualified references are handled separately so that their #N
+		// part is never mistaken for a bare reference.
+		var updated strings.Builder
+		last := 0
+		for _, m := range qualifiedIssueLinkRegex.FindAllStringSubmatchIndex(prose, -1) {
+			updated.WriteString(rewriteBare(prose[last:m[0]]))
+			match := prose[m[0]:m[1]]
+			if sourceRepo != "" && strings.EqualFold(prose[m[4]:m[5]], sourceRepo) {
+				match = rewriteNumber(match, func(_ string, newNum int) string {
+					return fmt.Sprintf("%s%s#%d", prose[m[2]:m[3]], targetRepo, newNum)
+				})
+			}
+			updated.WriteString(match)
+			last = m[1]
+		}
+		updated.WriteString(rewriteBare(prose[last:]))
+		return updated.String()
+	})
+	return updated, rewrites, unresolved
+}
+
+// rewriteOutsideCode applies rewrite to every part of text that lies outside
+// code blocks and inline code spans, copying the code itself unchanged.
+func rewriteOutsideCode(text string, rewrite func(prose string) string) string {
+	var updated strings.Builder
+	last := 0
+	for _, span := range codeSpanRegex.FindAllStringIndex(text, -1) {
+		updated.WriteString(rewrite(text[last:span[0]]))
+		updated.WriteString(text[span[0]:span[1]])
+		last = span[1]
+	}
+	updated.WriteString(rewrite(text[last:]))
+	return updated.String()
+}
+
+// targetRepo returns the owner/name of the target repository.
+func (im *importer) targetRepo() string {
+	return im.owner + "/" + im.repo
+}
+
+// updateIssueLinks rewrites the issue references in the bodies and comments of
+// every created issue. It stops when ctx is done, or with --fail-fast at the
+// first failed edit.
+func (im *importer) updateIssueLinks(ctx context.Context, issues []Issue, oldToNewIssueNumbers map[int]int) error {
+	for _, sourceIssue := range issues {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		newlyCreatedNumber, ok := oldToNewIssueNumbers[sourceIssue.Number]
+		if !ok {
+			infof("Skipping body update for old issue #%d as it was not created.", sourceIssue.Number)
+			continue
+		}
+		body, comments := im.linkRewriteScope(sourceIssue)
+		if !body && !comments {
+			infof("Skipping link updates for old issue #%d, issue #%d was not created for it.", sourceIssue.Number, newlyCreatedNumber)
+			continue
+		}
+		if body {
+			if err := im.updateBodyLinks(ctx, sourceIssue, newlyCreatedNumber, oldToNewIssueNumbers); err != nil {
+				return err
+			}
+		}
+		if comments {
+			if err := im.updateCommentLinks(ctx, sourceIssue, newlyCreatedNumber, oldToNewIssueNumbers); err != nil {
+				return err
+			}
+		}
+	}
+	im.findDanglingLinks(issues, oldToNewIssueNumbers)
+	return nil
+}
+
+// linkRewriteScope returns whether Phase 4 rewrites the links in the body and
+// in the comments of the issue a source issue was mapped to. Both are
+// rewritten for issues this run created, and for issues an earlier run created
+// and --mapping-in lists. Neither is for issues mapped onto an issue that was
+// not created for them, which are only link targets. A resumed entry's body
+// was already rewritten by the run that created it, so only its comments are.
+func (im *importer) linkRewriteScope(sourceIssue Issue) (body, comments bool) {
+	switch {
+	case im.linkTargetsOnly[sourceIssue.Number]:
+		return false, false
+	case sourceIssue.ImportedAs != 0:
+		return false, true
+	default:
+		return true, true
+	}
+}
+
+// findDanglingLinks records in the report, per created issue, the #N
+// references in its source body and comments that could not be remapped
+// because no issue was created for them. References up to the highest known
+// source number most likely point to issues that failed or were filtered out,
+// or to pull requests; higher ones are probably not issue references at all.
+func (im *importer) findDanglingLinks(issues []Issue, oldToNewIssueNumbers map[int]int) {
+	highest := 0
+	for _, issue := range issues {
+		highest = max(highest, issue.Number)
+	}
+	for oldNum := range oldToNewIssueNumbers {
+		highest = max(highest, oldNum)
+	}
+
+	for _, sourceIssue := range issues {
+		newlyCreatedNumber, ok := oldToNewIssueNumbers[sourceIssue.Number]
+		if !ok {
+			continue
+		}
+		body, comments := im.linkRewriteScope(sourceIssue)
+		var texts []string
+		if body {
+			texts = append(texts, sourceIssue.Body)
+		}
+		if comments {
+			for _, comment := range sourceIssue.Comments {
+				texts = append(texts, comment.Body)
+			}
+		}
+
+		dangling := danglingLink{Number: newlyCreatedNumber, OldNumber: sourceIssue.Number}
+		seen := make(map[int]bool)
+		for _, text := range texts {
+			_, _, unresolved := rewriteIssueLinks(text, oldToNewIssueNumbers, im.sourceRepo, im.targetRepo())
+			for _, oldNum := range unresolved {
+				if seen[oldNum] {
+					continue
+				}
+				seen[oldNum] = true
+				if oldNum <= highest {
+					dangling.Missing = append(dangling.Missing, oldNum)
+				} else {
+					dangling.Unknown = append(dangling.Unknown, oldNum)
+				}
+			}
+		}
+		if len(seen) == 0 {
+			continue
+		}
+		slices.Sort(dangling.Missing)
+		slices.Sort(dangling.Unknown)
+		im.report.DanglingLinks = append(im.report.DanglingLinks, dangling)
+	}
+}
+
+// updateBodyLinks rewrites the issue references in the body of a new issue. A
+// failure is only returned with --fail-fast.
+func (im *importer) updateBodyLinks(ctx context.Context, sourceIssue Issue, newlyCreatedNumber int, oldToNewIssueNumbers map[int]int) error {
+	// The overflow of a truncated body was posted as a comment, whose links
+	// are rewritten along with the other comments.
+	head, _ := im.splitSourceBody(sourceIssue)
+	updatedHead, rewrites, _ := rewriteIssueLinks(head, oldToNewIssueNumbers, im.sourceRepo, im.targetRepo())
+	if updatedHead == head {
+		return nil
+	}
+	updatedBody := im.issueBody(sourceIssue, updatedHead)
+
+	if im.dryRun {
+		for _, rw := range rewrites {
+			infof("[dry-run] Would rewrite #%d to #%d in issue #%d", rw.oldNum, rw.newNum, newlyCreatedNumber)
+		}
+		infof("[dry-run] Would update body for new issue #%d (from old #%d)", newlyCreatedNumber, sourceIssue.Number)
+		return nil
+	}
+
+	infof("Updating body for new issue #%d (from old #%d)...", newlyCreatedNumber, sourceIssue.Number)
+	updateReq := &github.IssueRequest{Body: &updatedBody}
+	err := im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.issues.Edit(ctx, im.owner, im.repo, newlyCreatedNumber, updateReq)
+		return resp, err
+	})
+	if err != nil {
+		eventf(levelQuiet, logFields{Action: "link_update_failed", IssueNumber: newlyCreatedNumber, OldNumber: sourceIssue.Number, Error: err.Error()}, "Failed to update body for new issue #%d: %v\n", newlyCreatedNumber, err)
+		im.report.EditsFailed++
+		im.report.LinkUpdatesFailed = append(im.report.LinkUpdatesFailed, linkFailure{Number: newlyCreatedNumber, OldNumber: sourceIssue.Number, Error: err.Error()})
+		if im.failFast {
+			return fmt.Errorf("failed to update body for new issue #%d: %v", newlyCreatedNumber, err)
+		}
+		return nil
+	}
+	eventf(levelNormal, logFields{Action: "links_rewritten", IssueNumber: newlyCreatedNumber, OldNumber: sourceIssue.Number}, "Success!\n")
+	im.report.LinksRewritten += len(rewrites)
+	return nil
+}
+
+// updateCommentLinks rewrites the issue references in the comments that were
+// posted on a new issue during Phase 3. A failure is only returned with
+// --fail-fast.
+func (im *importer) updateCommentLinks(ctx context.Context, sourceIssue Issue, newlyCreatedNumber int, oldToNewIssueNumbers map[int]int) error {
+	if im.dryRun {
+		// Nothing was posted, so plan against the source comments instead.
+		for _, comment := range sourceIssue.Comments {
+			_, rewrites, _ := rewriteIssueLinks(comment.Body, oldToNewIssueNumbers, im.sourceRepo, im.targetRepo())
+			for _, rw := range rewrites {
+				infof("[dry-run] Would rewrite #%d to #%d in a comment on issue #%d", rw.oldNum, rw.newNum, newlyCreatedNumber)
+			}
+		}
+		return nil
+	}
+
+	comments := im.postedComments[newlyCreatedNumber]
+	if im.previouslyImported[sourceIssue.Number] {
+		var err error
+		comments, err = im.importedComments(ctx, newlyCreatedNumber)
+		if err != nil {
+			eventf(levelQuiet, logFields{Action: "link_update_failed", IssueNumber: newlyCreatedNumber, OldNumber: sourceIssue.Number, Error: err.Error()}, "Failed to list the comments on new issue #%d: %v\n", newlyCreatedNumber, err)
+			im.report.EditsFailed++
+			im.report.LinkUpdatesFailed = append(im.report.LinkUpdatesFailed, linkFailure{Number: newlyCreatedNumber, OldNumber: sourceIssue.Number, Error: err.Error()})
+			if im.failFast {
+				return fmt.Errorf("failed to list the comments on new issue #%d: %v", newlyCreatedNumber, err)
+			}
+			return nil
+		}
+	}
+
+	for _, comment := range comments {
+		updatedBody, rewrites, _ := rewriteIssueLinks(comment.body, oldToNewIssueNumbers, im.sourceRepo, im.targetRepo())
+		if updatedBody == comment.body {
+			continue
+		}
+
+		infof("Updating links in comment %d on new issue #%d...", comment.id, newlyCreatedNumber)
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			_, resp, err := im.issues.EditComment(ctx, im.owner, im.repo, comment.id, &github.IssueComment{Body: &updatedBody})
+			return resp, err
+		})
+		if err != nil {
+			eventf(levelQuiet, logFields{Action: "link_update_failed", IssueNumber: newlyCreatedNumber, OldNumber: sourceIssue.Number, Error: err.Error()}, "Failed to update comment %d on new issue #%d: %v\n", comment.id, newlyCreatedNumber, err)
+			im.report.EditsFailed++
+			im.report.LinkUpdatesFailed = append(im.report.LinkUpdatesFailed, linkFailure{Number: newlyCreatedNumber, OldNumber: sourceIssue.Number, CommentID: comment.id, Error: err.Error()})
+			if im.failFast {
+				return fmt.Errorf("failed to update comment %d on new issue #%d: %v", comment.id, newlyCreatedNumber, err)
+			}
+			continue
+		}
+		im.report.LinksRewritten += len(rewrites)
+	}
+	return nil
+}
+
+// importedComments returns the comments an earlier run posted on the new issue
+// number, for issues skipped because of --mapping-in. The earlier run created
+// the issue, so they are the comments by the issue's author; comments others
+// added since are left alone.
+func (im *importer) importedComments(ctx context.Context, number int) ([]postedComment, error) {
+	var issue *github.Issue
+	err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+		issue, resp, err = im.issues.Get(ctx, im.owner, im.repo, number)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	author := issue.GetUser().GetLogin()
+
+	var comments []postedComment
+	listOpts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var page []*github.IssueComment
+		var resp *github.Response
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			var err error
+			page, resp, err = im.issues.ListComments(ctx, im.owner, im.repo, number, listOpts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range page {
+			if author != "" && strings.EqualFold(comment.GetUser().GetLogin(), author) {
+				comments = append(comments, postedComment{id: comment.GetID(), body: comment.GetBody()})
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+	return comments, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v73/github"
+)
+
+func TestUpdateIssueLinks(t *testing.T) {
+	mapping := map[int]int{1: 11, 2: 12}
+	tests := []struct {
+		name      string
+		issue     Issue
+		comment   string
+		wantBody  string
+		wantEdits int
+		wantComm  string
+	}{
+		{
+			name:      "body references are remapped",
+			issue:     Issue{Number: 1, Body: "Duplicate of #2."},
+			wantBody:  "Duplicate of #12.",
+			wantEdits: 1,
+		},
+		{
+			name:  "body without references is not edited",
+			issue: Issue{Number: 1, Body: "Nothing to see."},
+		},
+		{
+			name:     "comment references are remapped",
+			issue:    Issue{Number: 2, Body: "plain"},
+			comment:  "Fixed together with #1 and #99.",
+			wantComm: "Fixed together with #11 and #99.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeIssues{}
+			im := newTestImporter(f)
+			newNumber := mapping[tt.issue.Number]
+			if tt.comment != "" {
+				im.postedComments[newNumber] = []postedComment{{id: 42, body: tt.comment}}
+			}
+
+			if err := im.updateIssueLinks(context.Background(), []Issue{tt.issue}, mapping); err != nil {
+				t.Fatalf("updateIssueLinks: %v", err)
+			}
+			edits := f.edits[newNumber]
+			if len(edits) != tt.wantEdits {
+				t.Fatalf("got %d edits of #%d, want %d", len(edits), newNumber, tt.wantEdits)
+			}
+			if tt.wantEdits > 0 && edits[0].GetBody() != tt.wantBody {
+				t.Errorf("body %q, want %q", edits[0].GetBody(), tt.wantBody)
+			}
+			if got := f.editedComments[42]; got != tt.wantComm {
+				t.Errorf("comment %q, want %q", got, tt.wantComm)
+			}
+		})
+	}
+}
+
+func TestRewriteIssueLinksSkipsCode(t *testing.T) {
+	mapping := map[int]int{1: 101, 2: 102}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"prose", "See #1 and #2.", "See #101 and #102."},
+		{"inline code", "Run `grep #1` as in #1.", "Run `grep #1` as in #101."},
+		{"fenced block", "Before #1\n```\n# comment #2\n```\nafter #2", "Before #101\n```\n# comment #2\n```\nafter #102"},
+		{"fence with language", "```sh\necho #1\n```\n#1", "```sh\necho #1\n```\n#101"},
+		{"unterminated fence", "See #1\n```\ncolor: #2", "See #101\n```\ncolor: #2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, _ := rewriteIssueLinks(tt.text, mapping, "", "o/r")
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteIssueLinksIgnoresNonReferences(t *testing.T) {
+	mapping := map[int]int{12: 112, 123: 223, 1234: 2234, 39: 139}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"hex color", "color: #1234ab;", "color: #1234ab;"},
+		{"digits followed by a letter", "step #12a", "step #12a"},
+		{"line anchor", "main.go#L123", "main.go#L123"},
+		{"html entity", "it&#39;s", "it&#39;s"},
+		{"references next to lookalikes", "#12 is not #12a, #1234ab, or &#39; but #123 is.", "#112 is not #12a, #1234ab, or &#39; but #223 is."},
+		{"punctuation around references", "(#12), #123.", "(#112), #223."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, _ := rewriteIssueLinks(tt.text, mapping, "", "o/r")
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteIssueLinksQualified(t *testing.T) {
+	mapping := map[int]int{5: 105}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"same repository", "Follows src/repo#5.", "Follows dst/new#105."},
+		{"same repository in other case", "See Src/Repo#5", "See dst/new#105"},
+		{"same repository, unmapped number", "See src/repo#6", "See src/repo#6"},
+		{"foreign repository", "Upstream: other/lib#5", "Upstream: other/lib#5"},
+		{"mixed", "other/lib#5 and src/repo#5 and #5", "other/lib#5 and dst/new#105 and #105"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, _ := rewriteIssueLinks(tt.text, mapping, "src/repo", "dst/new")
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateIssueLinksRewritesCommentsOfMappedIssues(t *testing.T) {
+	f := &fakeIssues{
+		issuePages: [][]*github.Issue{{{Number: github.Ptr(5), User: &github.User{Login: github.Ptr("migrator[bot]")}}}},
+		existingComments: map[int][]*github.IssueComment{
+			5: {
+				{ID: github.Ptr(int64(501)), User: &github.User{Login: github.Ptr("migrator[bot]")}, Body: github.Ptr("migrated comment about #2")},
+				{ID: github.Ptr(int64(502)), User: &github.User{Login: github.Ptr("someone")}, Body: github.Ptr("later comment about #2")},
+			},
+		},
+		nextNumber: 10,
+	}
+	im := newTestImporter(f)
+	issues := []Issue{
+		{Number: 1, Title: "one", Body: "see #2", Comments: []Comment{{Body: "migrated comment about #2", Author: User{Login: "a"}}}},
+		{Number: 2, Title: "two"},
+	}
+
+	ctx := context.Background()
+	mapping, err := im.createIssueAndComment(ctx, issues, nil, map[int]int{1: 5})
+	if err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if err := im.updateIssueLinks(ctx, issues, mapping); err != nil {
+		t.Fatalf("updateIssueLinks: %v", err)
+	}
+
+	if edits := f.edits[5]; len(edits) != 1 || !strings.Contains(edits[0].GetBody(), "see #10") {
+		t.Errorf("issue #5 edits %v, want its body rewritten to link #10", edits)
+	}
+	if got := f.editedComments[501]; got != "migrated comment about #10" {
+		t.Errorf("migrated comment rewritten to %q, want it to link #10", got)
+	}
+	if got, ok := f.editedComments[502]; ok {
+		t.Errorf("comment by someone else was edited to %q", got)
+	}
+}
+
+func TestLinkRewriteScope(t *testing.T) {
+	im := newTestImporter(&fakeIssues{})
+	im.previouslyImported[2] = true
+	im.linkTargetsOnly[3] = true
+	tests := []struct {
+		name         string
+		issue        Issue
+		wantBody     bool
+		wantComments bool
+	}{
+		{"created in this run", Issue{Number: 1}, true, true},
+		{"listed in --mapping-in", Issue{Number: 2}, true, true},
+		{"mapped onto an existing issue", Issue{Number: 3}, false, false},
+		{"resumed", Issue{Number: 4, ImportedAs: 40}, false, true},
+	}
+	for _, tt := range tests {
+		body, comments := im.linkRewriteScope(tt.issue)
+		if body != tt.wantBody || comments != tt.wantComments {
+			t.Errorf("%s: linkRewriteScope = %v, %v, want %v, %v", tt.name, body, comments, tt.wantBody, tt.wantComments)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLevel controls how much progress is logged.
+type logLevel int
+
+const (
+	// levelQuiet logs only phase headers, warnings, failures, and the
+	// final summary.
+	levelQuiet logLevel = iota
+	// levelNormal also logs every label, milestone, issue, comment, and
+	// edit as it is processed.
+	levelNormal
+	// levelVerbose also logs every API request and the remaining rate
+	// limit.
+	levelVerbose
+)
+
+// currentLogLevel is set from --quiet and --verbose.
+var currentLogLevel = levelNormal
+
+// infof logs a per-item progress message, unless --quiet is set.
+func infof(format string, args ...any) {
+	if currentLogLevel >= levelNormal {
+		log.Printf(format, args...)
+	}
+}
+
+// debugf logs a message only when --verbose is set.
+func debugf(format string, args ...any) {
+	if currentLogLevel >= levelVerbose {
+		log.Printf(format, args...)
+	}
+}
+
+// loggingTransport logs every request sent to GitHub along with the response
+// status, how long it took, and the remaining rate limit.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		debugf("%s %s failed after %s: %v", req.Method, req.URL, elapsed, err)
+		return resp, err
+	}
+	debugf("%s %s -> %s in %s (rate limit: %s of %s remaining)", req.Method, req.URL, resp.Status, elapsed,
+		resp.Header.Get("X-RateLimit-Remaining"), resp.Header.Get("X-RateLimit-Limit"))
+	return resp, nil
+}
+
+// currentPhase is the number of the phase being run, attached to every JSON
+// log entry. It is only changed between phases.
+var currentPhase int
+
+// phaseStarted is when the current phase started, or zero once it has been
+// timed.
+var phaseStarted time.Time
+
+// phaseDurations are the wall-clock durations of the phases finished so far.
+var phaseDurations []phaseDuration
+
+// phaseDuration is how long a phase took.
+type phaseDuration struct {
+	Phase   int     `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
+
+// startPhase logs the header of a phase and records it as the current one,
+// finishing the previous phase.
+func startPhase(phase int, description string) {
+	finishPhase()
+	currentPhase = phase
+	phaseStarted = time.Now()
+	log.Printf("Phase %d: %s", phase, description)
+}
+
+// finishPhase records the duration of the current phase, if it has not been
+// recorded yet.
+func finishPhase() {
+	if phaseStarted.IsZero() {
+		return
+	}
+	phaseDurations = append(phaseDurations, phaseDuration{Phase: currentPhase, Seconds: time.Since(phaseStarted).Seconds()})
+	phaseStarted = time.Time{}
+}
+
+// logFields are the structured details of a log event, which are only
+// visible in JSON logs; text logs show the formatted message alone.
+type logFields struct {
+	Action      string `json:"action,omitempty"`
+	Name        string `json:"name,omitempty"`
+	IssueNumber int    `json:"issueNumber,omitempty"`
+	OldNumber   int    `json:"oldNumber,omitempty"`
+	NewNumber   int    `json:"newNumber,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// logEntry is a single line of JSON log output.
+type logEntry struct {
+	Time  string `json:"time"`
+	Phase int    `json:"phase,omitempty"`
+	logFields
+	Message string `json:"message"`
+}
+
+// jsonLogs is the writer installed by --log-format json, or nil for text logs.
+var jsonLogs *jsonLogWriter
+
+// jsonLogWriter turns every message written by the log package into a JSON
+// log entry.
+type jsonLogWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// useJSONLogs switches the log package to one JSON object per line on out.
+func useJSONLogs(out io.Writer) {
+	jsonLogs = &jsonLogWriter{out: out}
+	log.SetFlags(0)
+	log.SetOutput(jsonLogs)
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	if err := w.writeEntry(logFields{}, strings.TrimSpace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *jsonLogWriter) writeEntry(fields logFields, message string) error {
+	data, err := json.Marshal(logEntry{
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		Phase:     currentPhase,
+		logFields: fields,
+		Message:   message,
+	})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(append(data, '\n'))
+	return err
+}
+
+// eventf logs a message with structured fields when the log level is at least
+// minLevel. Failures use levelQuiet so that they are always logged.
+func eventf(minLevel logLevel, fields logFields, format string, args ...any) {
+	if currentLogLevel < minLevel {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	if jsonLogs == nil {
+		log.Print(message)
+		return
+	}
+	if err := jsonLogs.writeEntry(fields, strings.TrimSpace(message)); err != nil {
+		log.Printf("Warning: failed to write log entry: %v", err)
+	}
+}
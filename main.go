@@ -5,28 +5,89 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"net/url"
 	"os"
-	"regexp"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/go-github/v73/github"
 	"golang.org/x/oauth2"
 )
 
-// Use gh issue list --state "open" --repo github.ibm.com/decentralized-trust-research/scalable-committer --json body,closed,closedAt,comments,createdAt,isPinned,labels,milestone,number,state,stateReason,title,updatedAt > issues.json
+// Use gh issue list --state "open" --repo github.ibm.com/decentralized-trust-research/scalable-committer --json assignees,author,body,closed,closedAt,comments,createdAt,isPinned,labels,milestone,number,state,stateReason,title,updatedAt > issues.json
 // to download existing issues to a json file. Change the repo name as per the need.
 type Issue struct {
-	Number    int        `json:"number"`
-	Title     string     `json:"title"`
-	Body      string     `json:"body"`
-	CreatedAt string     `json:"createdAt"`
-	Labels    []Label    `json:"labels"`
-	Comments  []Comment  `json:"comments"`
-	Milestone *Milestone `json:"milestone"`
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Author      User       `json:"author"`
+	CreatedAt   string     `json:"createdAt"`
+	UpdatedAt   string     `json:"updatedAt"`
+	State       string     `json:"state"`
+	Closed      bool       `json:"closed"`
+	IsPinned    bool       `json:"isPinned"`
+	StateReason string     `json:"stateReason"`
+	Labels      []Label    `json:"labels"`
+	Assignees   []User     `json:"assignees"`
+	Comments    []Comment  `json:"comments"`
+	Milestone   *Milestone `json:"milestone"`
+
+	Locked           bool   `json:"locked"`
+	ActiveLockReason string `json:"activeLockReason"`
+
+	Reactions []ReactionGroup `json:"reactionGroups"`
+	Parent    *IssueRef       `json:"parent"`
+
+	// ImportedAs is only set in --failures-out entries for issues that were
+	// created but lost some of their comments. The entry then holds just
+	// those comments, and --retry-from posts them on issue ImportedAs
+	// instead of creating the issue again. Its body and sub-issue link are
+	// left as the earlier run wrote them.
+	ImportedAs int `json:"importedAs,omitempty"`
+}
+
+// isClosed reports whether the source issue was closed. gh reports the state
+// in upper case ("OPEN"/"CLOSED"), so the comparison ignores case.
+func (i Issue) isClosed() bool {
+	return i.Closed || strings.EqualFold(i.State, "closed")
+}
+
+// closeReason maps the exported stateReason ("COMPLETED", "NOT_PLANNED", ...)
+// onto the values accepted by the REST API, defaulting to "completed" when the reason is missing or unknown.
+func (i Issue) closeReason() string {
+	if strings.EqualFold(i.StateReason, "not_planned") {
+		return "not_planned"
+	}
+	return "completed"
+}
+
+// lockReason maps the exported activeLockReason, in either its GraphQL form
+// ("OFF_TOPIC", "TOO_HEATED", ...) or its REST form ("off-topic", "too heated",
+// ...), onto the values accepted by the REST API. An unknown reason maps to "",
+// which locks the issue without a reason.
+func (i Issue) lockReason() string {
+	switch strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(i.ActiveLockReason)) {
+	case "off topic":
+		return "off-topic"
+	case "too heated":
+		return "too heated"
+	case "resolved":
+		return "resolved"
+	case "spam":
+		return "spam"
+	}
+	return ""
 }
 
 type Label struct {
@@ -39,255 +100,2079 @@ type Milestone struct {
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
 	DueOn       *string `json:"dueOn"`
+	State       string  `json:"state"`
+	Closed      bool    `json:"closed"`
+	ClosedAt    *string `json:"closedAt"`
 }
 
 type Comment struct {
-	Body   string `json:"body"`
-	Author User   `json:"author"`
+	Body      string          `json:"body"`
+	Author    User            `json:"author"`
+	CreatedAt string          `json:"createdAt"`
+	Reactions []ReactionGroup `json:"reactionGroups"`
 }
 
 type User struct {
 	Login string `json:"login"`
+	IsBot bool   `json:"is_bot,omitempty"`
+}
+
+// displayName returns how the user is named in migrated issues and comments:
+// an @mention for people, the bare name for bots so that no broken mention is
+// created, and "a deleted user" for accounts that no longer exist, which are
+// exported with an empty login or as GitHub's "ghost" user.
+func (u User) displayName() string {
+	login := strings.TrimPrefix(u.Login, "app/")
+	switch {
+	case login == "" || login == "ghost":
+		return "a deleted user"
+	case u.IsBot || login != u.Login || strings.HasSuffix(login, "[bot]"):
+		return login
+	default:
+		return "@" + login
+	}
 }
 
 func main() {
-	jsonPath := flag.String("file", "", "Path to the JSON file containing the issue data array.")
+	var jsonPaths stringList
+	flag.Var(&jsonPaths, "file", "Path to a JSON file containing an issue data array, or - to read it from stdin. May be repeated or comma-separated.")
 	owner := flag.String("owner", "", "Owner of the target GitHub repository.")
 	repo := flag.String("repo", "", "Name of the target GitHub repository.")
+	dryRun := flag.Bool("dry-run", false, "Log the planned changes without modifying the target repository.")
+	mappingOut := flag.String("mapping-out", "", "Optional path to write the old-to-new issue number mapping as JSON.")
+	maxRetries := flag.Int("max-retries", 3, "Maximum number of times an API call is retried after a rate limit or a transient error.")
+	retryBase := flag.Duration("retry-base", defaultRetryBase, "Initial wait before retrying a call that failed with a 5xx status or a network error; doubled for every further retry.")
+	retryMax := flag.Duration("retry-max", defaultRetryMax, "Longest wait between retries of a call that failed with a 5xx status or a network error.")
+	separateComments := flag.Bool("separate-comments", false, "Post each source comment as its own comment instead of consolidating them into one.")
+	rps := flag.Float64("rps", 2, "Maximum number of mutating API calls per second; 0 disables throttling.")
+	mappingIn := flag.String("mapping-in", "", "Optional path to a mapping written by --mapping-out; issues listed in it are not created again.")
+	baseURL := flag.String("base-url", "", "Optional GitHub Enterprise Server URL, e.g. https://github.example.com/.")
+	token := flag.String("token", "", "GitHub token; takes precedence over --token-file and GITHUB_TOKEN.")
+	tokenFile := flag.String("token-file", "", "Path to a file containing the GitHub token; takes precedence over GITHUB_TOKEN.")
+	sourceRepo := flag.String("source-repo", "", "Optional source repository (owner/name); adds a \"Migrated from\" footer to every issue.")
+	preserveAuthors := flag.Bool("preserve-authors", false, "Start every issue body with a line naming the original author.")
+	userMapPath := flag.String("user-map", "", "Optional path to a JSON object mapping old logins to new ones, used to rewrite @mentions.")
+	labelMapPath := flag.String("label-map", "", "Optional path to a JSON object mapping old label names to new ones; an empty name drops the label.")
+	updateLabels := flag.Bool("update-labels", false, "Update the color and description of existing labels that differ from the source.")
+	updateMilestones := flag.Bool("update-milestones", false, "Update the open/closed state of existing milestones that differ from the source.")
+	reportOut := flag.String("report-out", "", "Optional path to write the end-of-run summary as JSON.")
+	failFast := flag.Bool("fail-fast", false, "Abort on the first failed create or edit instead of logging it and continuing.")
+	concurrency := flag.Int("concurrency", 1, "Number of issues to import in parallel.")
+	timeout := flag.Duration("timeout", 0, "Optional overall deadline for the run, e.g. 2h; 0 means no deadline.")
+	state := flag.String("state", "all", "Only import issues in this state: open, closed, or all.")
+	var filterLabels stringList
+	flag.Var(&filterLabels, "filter-label", "Only import issues carrying this label. May be repeated or comma-separated.")
+	var excludeLabels stringList
+	flag.Var(&excludeLabels, "exclude-label", "Leave this label out of every issue and do not create it. May be repeated or comma-separated.")
+	maxIssues := flag.Int("max-issues", 0, "Only import the first N issues after filtering, oldest first; 0 imports all of them.")
+	exportFrom := flag.String("export-from", "", "Export the issues of this repository (owner/name) to --file instead of importing.")
+	preserveLocks := flag.Bool("preserve-locks", false, "Lock new issues whose source issue was locked, with the same reason.")
+	preserveTimestamps := flag.Bool("preserve-timestamps", false, "Start every issue body with the original creation and last update dates.")
+	migrateReactions := flag.Bool("migrate-reactions", false, "Add the reactions of source issues and comments to the new ones, once per reaction type.")
+	skipExistingTitles := flag.Bool("skip-existing-titles", false, "Skip source issues whose title matches an issue already in the target repository.")
+	ignoreTitleCase := flag.Bool("ignore-title-case", false, "Compare titles case-insensitively with --skip-existing-titles.")
+	appID := flag.Int64("app-id", 0, "GitHub App ID; authenticates as an app installation instead of with a token.")
+	installationID := flag.Int64("installation-id", 0, "GitHub App installation ID, used with --app-id.")
+	privateKeyFile := flag.String("private-key-file", "", "Path to the GitHub App's PEM private key, used with --app-id.")
+	verbose := flag.Bool("verbose", false, "Also log every API request and the remaining rate limit.")
+	quiet := flag.Bool("quiet", false, "Only log phase headers, warnings, failures, and the final summary.")
+	logFormat := flag.String("log-format", "text", "Log format: text, or json for one JSON object per line.")
+	failuresOut := flag.String("failures-out", "", "Optional path to write the source JSON of every issue that could not be created.")
+	retryFrom := flag.String("retry-from", "", "Import the issues in a file written by --failures-out instead of --file.")
+	titlePrefix := flag.String("title-prefix", "", "Optional text prepended to the title of every new issue, e.g. \"[MIGRATED] \".")
+	skipInvalid := flag.Bool("skip-invalid", false, "Drop issues that fail validation instead of aborting.")
+	var typeFromLabels stringList
+	flag.Var(&typeFromLabels, "type-from-label", "Set the issue type of new issues from a label, e.g. bug=Bug,enhancement=Feature. May be repeated or comma-separated.")
+	removeTypeLabels := flag.Bool("remove-type-labels", false, "Leave out the label an issue's type was taken from with --type-from-label.")
+	projectID := flag.String("project-id", "", "Optional Projects v2 node ID, e.g. PVT_kwDOABCD; every new issue is added to that project.")
+	embedOldNumber := flag.Bool("embed-old-number", false, "Record the source issue number on every new issue, as chosen by --old-number-style.")
+	oldNumberStyle := flag.String("old-number-style", "anchor", "With --embed-old-number: anchor for a hidden <!-- original: #42 --> comment in the body, or title to append \"(was #42)\" to the title.")
+	commentHeader := flag.String("comment-header", defaultConsolidatedHeader, "Heading of the consolidated comment; empty leaves it out.")
+	commentAuthorFormat := flag.String("comment-author-format", defaultCommentAuthorFormat, "Go template for the line above every migrated comment, with {{.Author}} and {{.Date}}.")
+	since := flag.String("since", "", "Only import issues created or updated at or after this time, e.g. 2024-05-01 or 2024-05-01T10:00:00Z.")
+	deleteAll := flag.Bool("delete-all", false, "Clean up a trial run instead of importing: close the open issues carrying the tool's title prefix or old-number anchor. Requires --confirm.")
+	confirm := flag.String("confirm", "", "The target repository (owner/name), repeated to confirm --delete-all.")
+	cleanupReport := flag.String("cleanup-report", "", "With --delete-all, the --report-out of the trial run; the labels and milestones it created are deleted too.")
+	preserveNumbers := flag.Bool("preserve-numbers", false, "Give new issues their source numbers by filling gaps with closed placeholder issues. Needs an empty target repository.")
+	copyAttachments := flag.Bool("copy-attachments", false, "Copy the images attached to source issues and comments into the target repository and link to the copies.")
+	attachmentsBranch := flag.String("attachments-branch", "issue-attachments", "Branch of the target repository that --copy-attachments commits to; created from the default branch if missing.")
+	attachmentsPath := flag.String("attachments-path", "attachments", "Directory on --attachments-branch that --copy-attachments stores files in.")
+	targetMilestone := flag.String("target-milestone", "", "Optional milestone title that every new issue is put in instead of its source milestone; created if missing.")
+	var addLabels stringList
+	flag.Var(&addLabels, "add-label", "Label added to every new issue alongside its source labels, e.g. migrated. May be repeated or comma-separated.")
+	inputFormat := flag.String("input-format", "github", "Format of the --file input: github for gh issue list, or gitlab for GitLab's issues API.")
+	commentMaxAge := flag.Duration("comment-max-age", 0, "Leave out source comments older than this, e.g. 8760h for one year; 0 keeps all comments.")
+	fromSource := flag.Bool("from-source", false, "Read the issues and their comments directly from --source-repo instead of --file, migrating repository to repository in one run.")
+	sourceToken := flag.String("source-token", "", "Optional token for reading --source-repo with --from-source, when it differs from the target's token.")
+	planOut := flag.String("plan-out", "", "With --dry-run, optional path to write the planned labels, milestones, and issues as JSON.")
+	outputDir := flag.String("output-dir", "", "Optional directory collecting the mapping, report, and failures files, under default names unless --mapping-out, --report-out, or --failures-out say otherwise; created if missing.")
+	reconcileDefaults := flag.Bool("reconcile-defaults", false, "Update the color and description of GitHub's default labels (bug, enhancement, ...) in the target repository to match the source.")
+	commentBatchSize := flag.Int("comment-batch-size", 0, "Split the consolidated comment into one comment per this many source comments, each headed \"(part K of M)\"; 0 means no limit.")
+	twoPass := flag.Bool("two-pass", false, "Create all issues with placeholder bodies first, then write every body and comment once with its links already rewritten, instead of fixing links in Phase 4.")
+	onlyLabelsAndMilestones := flag.Bool("only-labels-and-milestones", false, "Only create the labels and milestones used by the source issues, then exit without importing any issue.")
+	noComments := flag.Bool("no-comments", false, "Import the issues without their comments.")
+	collapseComments := flag.String("collapse-comments", "", "Wrap the consolidated comment in collapsible blocks: comment for one per comment, author for one per author.")
+	assignTeam := flag.String("assign-team", "", "Optional slug of a team in the owning organization that is @mentioned on every new issue, since issues cannot be assigned to teams.")
+	ownerType := flag.String("owner-type", "auto", "Whether --owner is a user or an org, for creating repositories and assigning teams; auto looks it up.")
+	createRepo := flag.Bool("create-repo", false, "Create the target repository under --owner, a user or an organization, if it does not exist yet.")
+	private := flag.Bool("private", false, "With --create-repo, make the new repository private.")
+	description := flag.String("description", "", "With --create-repo, the description of the new repository.")
+	normalizeLabelNames := flag.Bool("normalize-labels", false, "Lowercase and trim label names so that case variants like Bug and BUG collapse into one label.")
+	verify := flag.Bool("verify", false, "Instead of importing, check that every issue in --mapping-in matches its source issue's title, labels, milestone, and comment count, and report label color or description drift. Changes nothing.")
+	configPath := flag.String("config", "", "Optional path to a JSON or YAML file setting any of these options by flag name; command-line flags take precedence.")
 	flag.Parse()
 
-	if *jsonPath == "" || *owner == "" || *repo == "" {
+	if *configPath != "" {
+		values, err := loadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Error reading config file: %v", err)
+		}
+		if err := applyConfig(values); err != nil {
+			log.Fatalf("Invalid config file %s: %v", *configPath, err)
+		}
+	}
+
+	if *inputFormat != "github" && *inputFormat != "gitlab" {
+		log.Fatalf("Invalid --input-format %q: expected github or gitlab.", *inputFormat)
+	}
+
+	if *retryFrom != "" {
+		if len(jsonPaths) > 0 {
+			log.Fatal("--retry-from replaces --file, they cannot be used together.")
+		}
+		jsonPaths = stringList{*retryFrom}
+		// --failures-out always writes the issues in the github format.
+		*inputFormat = "github"
+	}
+
+	if *fromSource {
+		if *sourceRepo == "" {
+			log.Fatal("--from-source reads the issues of --source-repo, which must be set.")
+		}
+		if len(jsonPaths) > 0 || *exportFrom != "" {
+			log.Fatal("--from-source replaces --file and cannot be combined with --file, --retry-from, or --export-from.")
+		}
+		*inputFormat = "github"
+	} else if *sourceToken != "" {
+		log.Fatal("--source-token is only used with --from-source.")
+	}
+
+	if *exportFrom != "" {
+		if !validRepoName(*exportFrom) {
+			log.Fatalf("Invalid --export-from %q: expected owner/name.", *exportFrom)
+		}
+		if len(jsonPaths) != 1 {
+			log.Fatal("--export-from requires exactly one --file to write the issues to.")
+		}
+	} else if (len(jsonPaths) == 0 && !*deleteAll && !*fromSource) || *owner == "" || *repo == "" {
 		log.Println("All flags (--file, --owner, --repo) are required.")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	githubToken := os.Getenv("GITHUB_TOKEN")
-	if githubToken == "" {
-		log.Fatal("GITHUB_TOKEN environment variable not set.")
+	if *deleteAll && !strings.EqualFold(*confirm, *owner+"/"+*repo) {
+		log.Fatalf("--delete-all closes issues in %s/%s; pass --confirm %s/%s to proceed.", *owner, *repo, *owner, *repo)
 	}
 
-	client := github.NewClient(oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: githubToken},
-	)))
+	if *ownerType != "auto" && *ownerType != "user" && *ownerType != "org" {
+		log.Fatalf("Invalid --owner-type %q: expected user, org, or auto.", *ownerType)
+	}
 
-	issue, err := os.ReadFile(*jsonPath)
-	if err != nil {
-		log.Fatalf("Error reading JSON file: %v", err)
+	if *onlyLabelsAndMilestones && (*verify || *deleteAll) {
+		log.Fatal("--only-labels-and-milestones cannot be combined with --verify or --delete-all.")
 	}
 
-	var sourceIssues []Issue
-	if err := json.Unmarshal(issue, &sourceIssues); err != nil {
-		log.Fatalf("Error unmarshaling JSON data: %v", err)
+	if *commentMaxAge < 0 {
+		log.Fatalf("Invalid --comment-max-age %v: must not be negative.", *commentMaxAge)
+	}
+	if *planOut != "" && !*dryRun {
+		log.Fatal("--plan-out writes the plan of a dry run and requires --dry-run.")
+	}
+	if *createRepo && (*verify || *deleteAll) {
+		log.Fatal("--create-repo only applies to an import, not to --verify or --delete-all.")
 	}
-	log.Printf("Successfully parsed %d issues from the file.\n", len(sourceIssues))
 
-	// Sort issues by creation date, from oldest to newest
-	log.Println("Sorting issues by creation date, from oldest to newest")
-	sort.Slice(sourceIssues, func(i, j int) bool {
-		timeI, errI := time.Parse(time.RFC3339, sourceIssues[i].CreatedAt)
-		timeJ, errJ := time.Parse(time.RFC3339, sourceIssues[j].CreatedAt)
-		if errI != nil || errJ != nil {
-			return false
+	if *verify {
+		if *mappingIn == "" {
+			log.Fatal("--verify checks the issues of an earlier run and requires its --mapping-out file as --mapping-in.")
 		}
-		return timeI.Before(timeJ)
-	})
+		if *deleteAll {
+			log.Fatal("--verify and --delete-all cannot be used together.")
+		}
+	}
 
-	log.Println("Phase 1: Collecting unique labels and milestones")
-	labels, milestones := findLablesAndMilestones(sourceIssues)
+	if *preserveNumbers {
+		if *concurrency > 1 {
+			log.Fatal("--preserve-numbers creates issues one at a time and cannot be combined with --concurrency.")
+		}
+		if *mappingIn != "" {
+			log.Fatal("--preserve-numbers needs an empty target repository and cannot be combined with --mapping-in.")
+		}
+	}
 
-	log.Println("Phase 2: Creating labels and milestones in target repository")
-	if err := createLabels(client, *owner, *repo, labels); err != nil {
-		log.Fatalf("failed to create labels: %v", err)
+	if *sourceRepo != "" && !validRepoName(*sourceRepo) {
+		log.Fatalf("Invalid --source-repo %q: expected owner/name.", *sourceRepo)
+	}
+
+	switch *logFormat {
+	case "text":
+	case "json":
+		useJSONLogs(os.Stderr)
+	default:
+		log.Fatalf("Invalid --log-format %q: expected text or json.", *logFormat)
+	}
+
+	switch {
+	case *verbose && *quiet:
+		log.Fatal("--verbose and --quiet cannot be used together.")
+	case *verbose:
+		currentLogLevel = levelVerbose
+	case *quiet:
+		currentLogLevel = levelQuiet
+	}
+
+	if *outputDir != "" {
+		if err := os.MkdirAll(*outputDir, 0o755); err != nil {
+			log.Fatalf("Error creating --output-dir: %v", err)
+		}
+		*mappingOut = artifactPath(*outputDir, *mappingOut, "mapping.json")
+		*reportOut = artifactPath(*outputDir, *reportOut, "report.json")
+		*failuresOut = artifactPath(*outputDir, *failuresOut, "failures.json")
+		log.Printf("Writing the mapping, report, and failures to %s", *outputDir)
+	}
+
+	if *retryBase <= 0 || *retryMax < *retryBase {
+		log.Fatalf("Invalid --retry-base %s and --retry-max %s: both must be positive, and --retry-max at least --retry-base.", *retryBase, *retryMax)
+	}
+
+	if *state != "open" && *state != "closed" && *state != "all" {
+		log.Fatalf("Invalid --state %q: expected open, closed, or all.", *state)
+	}
+
+	switch *collapseComments {
+	case "", "comment", "author":
+	default:
+		log.Fatalf("Invalid --collapse-comments %q: expected comment or author.", *collapseComments)
+	}
+	if *commentBatchSize < 0 {
+		log.Fatalf("Invalid --comment-batch-size %d: expected a positive number, or 0 for no limit.", *commentBatchSize)
+	}
+	if *collapseComments != "" && *separateComments {
+		log.Fatal("--collapse-comments only applies to the consolidated comment and cannot be combined with --separate-comments.")
+	}
+
+	if *oldNumberStyle != "anchor" && *oldNumberStyle != "title" {
+		log.Fatalf("Invalid --old-number-style %q: expected anchor or title.", *oldNumberStyle)
+	}
+	if !*embedOldNumber {
+		*oldNumberStyle = ""
 	}
 
-	milestoneTitleToNumber, err := createMilestones(client, *owner, *repo, milestones)
+	typeMappings, err := parseTypeMappings(typeFromLabels)
 	if err != nil {
-		log.Fatalf("failed to create milestones: %v", err)
+		log.Fatalf("Invalid --type-from-label: %v", err)
 	}
 
-	log.Println("Phase 3: Creating issues and comments")
-	oldToNewIssueNumbers := createIssueAndComment(client, *owner, *repo, sourceIssues, milestoneTitleToNumber)
+	var sinceTime time.Time
+	if *since != "" {
+		if sinceTime, err = parseDate(*since); err != nil {
+			log.Fatalf("Invalid --since: %v", err)
+		}
+	}
 
-	log.Println("Phase 4: Updating issue bodies with new links")
-	updateIssueLinks(client, *owner, *repo, sourceIssues, oldToNewIssueNumbers)
+	commentAuthorTemplate, err := parseCommentAuthorFormat(*commentAuthorFormat)
+	if err != nil {
+		log.Fatalf("Invalid --comment-author-format: %v", err)
+	}
 
-	log.Println("\n All issues created and linked successfully! ---")
-}
+	var tokenSource oauth2.TokenSource
+	if *appID != 0 || *installationID != 0 || *privateKeyFile != "" {
+		if *appID == 0 || *installationID == 0 || *privateKeyFile == "" {
+			log.Fatal("--app-id, --installation-id, and --private-key-file must be used together.")
+		}
+		appSource, err := newAppTokenSource(*appID, *installationID, *privateKeyFile, *baseURL)
+		if err != nil {
+			log.Fatalf("Error reading GitHub App private key: %v", err)
+		}
+		tokenSource = oauth2.ReuseTokenSource(nil, appSource)
+		log.Printf("Authenticating as installation %d of GitHub App %d", *installationID, *appID)
+	} else {
+		githubToken, err := resolveToken(*token, *tokenFile)
+		if err != nil {
+			log.Fatalf("Error reading token file: %v", err)
+		}
+		if githubToken == "" {
+			log.Fatal("No GitHub token found: set --token, --token-file, or the GITHUB_TOKEN environment variable, or use --app-id.")
+		}
+		tokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: githubToken})
+	}
 
-func findLablesAndMilestones(issues []Issue) (map[string]Label, map[string]Milestone) {
-	uniqueLabels := make(map[string]Label)
-	uniqueMilestones := make(map[string]Milestone)
+	if *baseURL != "" {
+		if err := validateBaseURL(*baseURL); err != nil {
+			log.Fatalf("Invalid --base-url: %v", err)
+		}
+	}
+	client, err := newGitHubClient(tokenSource, *baseURL)
+	if err != nil {
+		log.Fatalf("Error configuring GitHub Enterprise URLs: %v", err)
+	}
+	if *baseURL != "" {
+		log.Printf("Using GitHub Enterprise Server at %s", client.BaseURL)
+	}
+	// sourceClient reads the source repository with --from-source, with its
+	// own token if one is given.
+	sourceClient := client
+	if *sourceToken != "" {
+		sourceClient, err = newGitHubClient(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: *sourceToken}), *baseURL)
+		if err != nil {
+			log.Fatalf("Error configuring GitHub Enterprise URLs: %v", err)
+		}
+	}
 
-	for _, issue := range issues {
-		for _, label := range issue.Labels {
-			uniqueLabels[label.Name] = label
+	// ctx is canceled on SIGINT or SIGTERM, or when --timeout expires, which
+	// stops the current phase and still writes the mapping file so the run can
+	// be resumed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Restore the default behavior so a second Ctrl-C exits immediately.
+		stop()
+	}()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if *exportFrom != "" {
+		sourceOwner, sourceName, _ := strings.Cut(*exportFrom, "/")
+		exported, err := exportIssues(ctx, client, sourceOwner, sourceName)
+		if err != nil {
+			log.Fatalf("Error exporting issues: %v", err)
 		}
-		if issue.Milestone != nil {
-			uniqueMilestones[issue.Milestone.Title] = *issue.Milestone
+		if err := writeIssues(jsonPaths[0], exported); err != nil {
+			log.Fatalf("Error writing exported issues: %v", err)
 		}
+		log.Printf("Exported %d issues from %s.\n", len(exported), *exportFrom)
+		return
 	}
-	log.Printf("Found %d unique labels and %d unique milestones.\n", len(uniqueLabels), len(uniqueMilestones))
 
-	return uniqueLabels, uniqueMilestones
-}
+	im := &importer{
+		client:     client,
+		issues:     client.Issues,
+		owner:      *owner,
+		repo:       *repo,
+		dryRun:     *dryRun,
+		maxRetries: *maxRetries,
+		retryBase:  *retryBase,
+		retryMax:   *retryMax,
+		throttle:   newThrottle(*rps),
 
-func createLabels(client *github.Client, owner, repo string, labels map[string]Label) error {
-	existingLabels, _, err := client.Issues.ListLabels(context.Background(), owner, repo, nil)
-	if err != nil {
-		return fmt.Errorf("failed to fetch existing labels: %v", err)
+		separateComments: *separateComments,
+		sourceRepo:       *sourceRepo,
+		webURL:           webURL(*baseURL),
+		preserveAuthors:  *preserveAuthors,
+		updateLabels:     *updateLabels,
+		updateMilestones: *updateMilestones,
+		postedComments:   make(map[int][]postedComment),
+		assignable:       make(map[string]bool),
+		report:           &report{},
+		failFast:         *failFast,
+		concurrency:      *concurrency,
+		preserveLocks:    *preserveLocks,
+
+		linkTargetsOnly:    make(map[int]bool),
+		previouslyImported: make(map[int]bool),
+		preserveTimestamps: *preserveTimestamps,
+		migrateReactions:   *migrateReactions,
+		skipExistingTitles: *skipExistingTitles,
+		ignoreTitleCase:    *ignoreTitleCase,
+		titlePrefix:        *titlePrefix,
+		projectID:          *projectID,
+		typeMappings:       typeMappings,
+		removeTypeLabels:   *removeTypeLabels,
+		oldNumberStyle:     *oldNumberStyle,
+
+		consolidatedHeader:    *commentHeader,
+		commentAuthorTemplate: commentAuthorTemplate,
+		preserveNumbers:       *preserveNumbers,
+		nextNumber:            1,
+		attachmentsBranch:     *attachmentsBranch,
+		attachmentsPath:       *attachmentsPath,
+		targetMilestone:       strings.TrimSpace(*targetMilestone),
+		addLabels:             addLabels,
+		collapseComments:      *collapseComments,
+		ownerType:             *ownerType,
+		twoPass:               *twoPass,
+		commentBatchSize:      *commentBatchSize,
+		reconcileDefaults:     *reconcileDefaults,
+		createdIssues:         make(map[int]*github.Issue),
+	}
+	if im.dryRun {
+		log.Println("Dry run: no changes will be made to the target repository.")
 	}
-	existingLabelNames := make(map[string]bool)
-	for _, label := range existingLabels {
-		existingLabelNames[label.GetName()] = true
+	if *planOut != "" {
+		im.plan = &plan{}
 	}
 
-	for name, label := range labels {
-		if !existingLabelNames[name] {
-			log.Printf("Creating label: [%s]", name)
-			_, _, err := client.Issues.CreateLabel(context.Background(), owner, repo, &github.Label{
-				Name:        &label.Name,
-				Color:       &label.Color,
-				Description: &label.Description,
-			})
-			if err != nil {
-				log.Printf("Warning: failed to create label [%s]: %v\n", name, err)
+	if *deleteAll {
+		if *titlePrefix == "" {
+			log.Println("No --title-prefix given; only issues with an --embed-old-number anchor are closed.")
+		}
+		var createdReport *report
+		if *cleanupReport != "" {
+			if createdReport, err = readReport(*cleanupReport); err != nil {
+				log.Fatalf("Error reading cleanup report: %v", err)
 			}
 		}
+		result, err := im.cleanup(ctx, createdReport)
+		log.Printf("Closed %d issues, deleted %d labels and %d milestones, %d failed.", result.issuesClosed, result.labelsDeleted, result.milestonesDeleted, result.failed)
+		if err != nil {
+			log.Fatalf("Aborting: %v", err)
+		}
+		if result.failed > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
-	return nil
-}
+	var sourceIssues []Issue
+	if *fromSource {
+		sourceOwner, sourceName, _ := strings.Cut(*sourceRepo, "/")
+		sourceIssues, err = exportIssues(ctx, sourceClient, sourceOwner, sourceName)
+		if err != nil {
+			log.Fatalf("Error reading issues from %s: %v", *sourceRepo, err)
+		}
+		log.Printf("Read %d issues from %s.\n", len(sourceIssues), *sourceRepo)
+	} else {
+		sourceIssues, err = loadIssues(jsonPaths, *inputFormat)
+		if err != nil {
+			log.Fatalf("Error loading issues: %v", err)
+		}
+		log.Printf("Successfully parsed %d issues from %d file(s).\n", len(sourceIssues), len(jsonPaths))
+	}
 
-func createMilestones(client *github.Client, owner, repo string, milestones map[string]Milestone) (map[string]int, error) {
-	milestoneTitleToNumber := make(map[string]int)
-	existingMilestones, _, err := client.Issues.ListMilestones(context.Background(), owner, repo, &github.MilestoneListOptions{State: "all"})
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch existing milestones: %v", err)
+	validIssues, problems := validateIssues(sourceIssues)
+	if len(problems) > 0 {
+		log.Printf("Found problems in %d issues:", len(problems))
+		for _, problem := range problems {
+			log.Printf("  %s", problem)
+		}
+		if !*skipInvalid {
+			log.Fatal("Aborting before any changes were made; fix the input or pass --skip-invalid to leave these issues out.")
+		}
+		log.Printf("Skipping the %d invalid issues (--skip-invalid).", len(problems))
 	}
-	for _, m := range existingMilestones {
-		milestoneTitleToNumber[m.GetTitle()] = m.GetNumber()
+	sourceIssues = validIssues
+
+	if *createRepo {
+		exists, err := im.ensureRepository(ctx, *private, *description)
+		if err != nil {
+			log.Fatalf("Error creating the target repository: %v", err)
+		}
+		if !exists {
+			log.Println("\n Dry run stopped: the rest of the plan needs the target repository to exist. ---")
+			return
+		}
 	}
 
-	for title, milestone := range milestones {
-		if _, exists := milestoneTitleToNumber[title]; exists {
-			continue
+	// --verify only reads from the target repository, so it does not need
+	// write access.
+	if !*verify {
+		if err := im.preflight(ctx); err != nil {
+			log.Fatalf("Preflight check failed: %v", err)
+		}
+	}
+	if im.preserveNumbers {
+		if err := im.ensureEmptyTarget(ctx); err != nil {
+			log.Fatalf("Preflight check failed: %v", err)
 		}
+		log.Println("Warning: --preserve-numbers creates a closed placeholder issue for every gap in the source numbers. These placeholders notify watchers and stay in the repository.")
+	}
 
-		log.Printf("Creating milestone: %s", title)
+	if *state != "all" {
+		sourceIssues = filterByState(sourceIssues, *state)
+		log.Printf("Kept %d %s issues.\n", len(sourceIssues), *state)
+	}
+	if !sinceTime.IsZero() {
+		sourceIssues = filterSince(sourceIssues, sinceTime)
+		log.Printf("Kept %d issues created or updated since %s.\n", len(sourceIssues), sinceTime.Format(time.RFC3339))
+	}
+	if len(filterLabels) > 0 {
+		sourceIssues = filterByLabel(sourceIssues, filterLabels, *normalizeLabelNames)
+		log.Printf("Kept %d issues labeled %v.\n", len(sourceIssues), []string(filterLabels))
+	}
 
-		newMilestoneReq := &github.Milestone{
-			Title:       &milestone.Title,
-			Description: &milestone.Description,
+	// Sort issues by creation date, from oldest to newest
+	log.Println("Sorting issues by creation date, from oldest to newest")
+	sort.Slice(sourceIssues, func(i, j int) bool {
+		timeI, errI := time.Parse(time.RFC3339, sourceIssues[i].CreatedAt)
+		timeJ, errJ := time.Parse(time.RFC3339, sourceIssues[j].CreatedAt)
+		if errI != nil || errJ != nil {
+			return false
 		}
+		return timeI.Before(timeJ)
+	})
 
-		if milestone.DueOn != nil {
-			parsedTime, err := time.Parse(time.RFC3339, *milestone.DueOn)
-			if err != nil {
-				log.Printf("Warning: could not parse due date for milestone '%s': %v. Creating without due date.", title, err)
-			} else {
-				newMilestoneReq.DueOn = &github.Timestamp{Time: parsedTime}
-			}
-		}
+	if *maxIssues > 0 && len(sourceIssues) > *maxIssues {
+		sourceIssues = sourceIssues[:*maxIssues]
+		log.Printf("Limiting the run to the first %d issues (--max-issues).\n", *maxIssues)
+	}
+	if *noComments {
+		log.Printf("Leaving out %d comments (--no-comments).\n", dropComments(sourceIssues))
+	}
+	if *commentMaxAge > 0 {
+		cutoff := time.Now().Add(-*commentMaxAge)
+		log.Printf("Leaving out %d comments posted before %s (--comment-max-age).\n", dropCommentsBefore(sourceIssues, cutoff), cutoff.Format(time.RFC3339))
+	}
+	if len(excludeLabels) > 0 {
+		log.Printf("Leaving out %d labels %v (--exclude-label).\n", dropLabels(sourceIssues, excludeLabels, *normalizeLabelNames), []string(excludeLabels))
+	}
 
-		createdMilestone, _, err := client.Issues.CreateMilestone(context.Background(), owner, repo, newMilestoneReq)
+	if *userMapPath != "" {
+		userMap, err := readStringMap(*userMapPath)
 		if err != nil {
-			log.Printf("Warning: failed to create milestone '%s': %v\n", title, err)
-		} else {
-			milestoneTitleToNumber[createdMilestone.GetTitle()] = createdMilestone.GetNumber()
+			log.Fatalf("Error reading user map: %v", err)
 		}
+		im.userMap = newUserMap(userMap)
+		log.Printf("Loaded %d user mappings from %s.\n", len(im.userMap), *userMapPath)
 	}
 
-	return milestoneTitleToNumber, nil
-}
+	previousMapping := make(map[int]int)
+	if *mappingIn != "" {
+		previousMapping, err = readMapping(*mappingIn)
+		if err != nil {
+			log.Fatalf("Error reading mapping file: %v", err)
+		}
+		log.Printf("Loaded %d previously imported issues from %s.\n", len(previousMapping), *mappingIn)
+	}
 
-func createIssueAndComment(client *github.Client, owner, repo string, issues []Issue, milestoneTitleToNum map[string]int) map[int]int {
-	oldToNewIssueNumbers := make(map[int]int)
-	for _, issue := range issues {
-		labelNames := make([]string, 0)
-		for _, label := range issue.Labels {
-			labelNames = append(labelNames, label.Name)
+	if *labelMapPath != "" {
+		labelMap, err := readStringMap(*labelMapPath)
+		if err != nil {
+			log.Fatalf("Error reading label map: %v", err)
+		}
+		applyLabelMap(sourceIssues, labelMap)
+	}
+	if *normalizeLabelNames {
+		normalizeLabels(sourceIssues)
+		for i, name := range im.addLabels {
+			im.addLabels[i] = strings.ToLower(strings.TrimSpace(name))
 		}
+	}
 
-		newIssueRequest := &github.IssueRequest{
-			Title:  &issue.Title,
-			Body:   &issue.Body,
-			Labels: &labelNames,
+	if *assignTeam != "" {
+		if err := im.resolveTeam(ctx, *assignTeam); err != nil {
+			log.Fatalf("Error resolving --assign-team: %v", err)
 		}
+	}
 
-		if issue.Milestone != nil {
-			if newMilestoneNum, ok := milestoneTitleToNum[issue.Milestone.Title]; ok {
-				newIssueRequest.Milestone = &newMilestoneNum
+	if *verify {
+		sanitizeLabels(sourceIssues)
+		log.Printf("Verifying %d issues against %s/%s", len(sourceIssues), im.owner, im.repo)
+		result, err := im.verify(ctx, sourceIssues, previousMapping)
+		log.Printf("Checked %d issues: %d match, %d differ, %d are not in the mapping.", result.Checked, result.Matched, len(result.Mismatches), len(result.NotImported))
+		if err == nil {
+			labels, _ := findLablesAndMilestones(sourceIssues)
+			result.LabelDrift, err = im.auditLabels(ctx, labels)
+			if len(result.LabelDrift) > 0 {
+				log.Printf("Warning: %d of %d labels differ from their source label in the target repository.", len(result.LabelDrift), len(labels))
+			}
+		}
+		if *reportOut != "" {
+			if err := result.write(*reportOut); err != nil {
+				log.Printf("Warning: failed to write verification report to %s: %v\n", *reportOut, err)
+			} else {
+				log.Printf("Wrote verification report to %s", *reportOut)
 			}
 		}
-
-		log.Printf("Creating issue for: \"%s\"...", issue.Title)
-		createdIssue, _, err := client.Issues.Create(context.Background(), owner, repo, newIssueRequest)
 		if err != nil {
-			log.Printf("Failed to create issue \"%s\": %v", issue.Title, err)
-			continue
+			log.Fatalf("Aborting: %v", err)
+		}
+		if !result.passed() {
+			log.Println("\n Verification failed, see the differences above. ---")
+			os.Exit(1)
 		}
+		log.Println("\n Verification passed, every issue matches its source. ---")
+		return
+	}
 
-		newlyCreatedNumber := createdIssue.GetNumber()
-		oldToNewIssueNumbers[issue.Number] = newlyCreatedNumber
+	if len(im.typeMappings) > 0 {
+		if err := im.loadIssueTypes(ctx); err != nil {
+			log.Fatalf("Error loading issue types: %v", err)
+		}
+	}
 
-		if len(issue.Comments) > 0 {
-			log.Printf("Consolidating %d comments for new issue #%d", len(issue.Comments), newlyCreatedNumber)
-			var combinedComments strings.Builder
-			combinedComments.WriteString("### Comments from original issue:\n\n---\n\n")
+	startPhase(1, "Collecting unique labels and milestones")
+	sanitizeLabels(sourceIssues)
+	labels, milestones := findLablesAndMilestones(sourceIssues)
+	im.includeAddedLabels(labels)
+	if im.targetMilestone != "" {
+		milestones = map[string]Milestone{milestoneKey(im.targetMilestone): {Title: im.targetMilestone, State: "open"}}
+	}
 
-			for _, comment := range issue.Comments {
-				commentHeader := fmt.Sprintf("**Comment from @%s:**\n\n", comment.Author.Login)
-				combinedComments.WriteString(commentHeader)
-				combinedComments.WriteString(comment.Body)
-				combinedComments.WriteString("\n\n---\n\n")
-			}
+	startPhase(2, "Creating labels and milestones in target repository")
+	if err := im.createLabels(ctx, labels); err != nil {
+		log.Fatalf("failed to create labels: %v", err)
+	}
 
-			if combinedComments.Len() > 0 {
-				combinedBody := combinedComments.String()
-				issueComment := &github.IssueComment{Body: &combinedBody}
-				_, _, err := client.Issues.CreateComment(context.Background(), owner, repo, newlyCreatedNumber, issueComment)
-				if err != nil {
-					log.Printf("Failed to create consolidated comment for issue #%d: %v\n", newlyCreatedNumber, err)
-				} else {
-					log.Printf("Successfully posted consolidated comments.\n")
-				}
-			}
+	milestoneTitleToNumber, err := im.createMilestones(ctx, milestones)
+	if err != nil {
+		log.Fatalf("failed to create milestones: %v", err)
+	}
+
+	if *onlyLabelsAndMilestones {
+		if im.dryRun {
+			im.writePlan(*planOut)
+			log.Println("\n Dry run complete, no changes were made. ---")
+			return
+		}
+		im.summarize(*reportOut)
+		if im.report.hasFailures() {
+			log.Println("\n Labels and milestones finished with failures, see the summary above. ---")
+			os.Exit(1)
+		}
+		log.Println("\n All labels and milestones created successfully, no issues were imported (--only-labels-and-milestones). ---")
+		return
+	}
+
+	if *copyAttachments {
+		log.Printf("Copying image attachments to %s on branch %s", im.attachmentsPath, im.attachmentsBranch)
+		if err := im.copyAttachments(ctx, sourceIssues); err != nil {
+			log.Fatalf("Aborting: %v", err)
 		}
 	}
 
-	return oldToNewIssueNumbers
+	startPhase(3, "Creating issues and comments")
+	oldToNewIssueNumbers, err := im.createIssueAndComment(ctx, sourceIssues, milestoneTitleToNumber, previousMapping)
+	im.writeMapping(*mappingOut, oldToNewIssueNumbers)
+	im.writeFailures(*failuresOut)
+	if err != nil {
+		log.Fatalf("Aborting: %v", err)
+	}
+
+	startPhase(4, "Updating issue bodies and comments with new links")
+	if im.twoPass && !im.dryRun {
+		log.Println("Links were written with the final numbers in Phase 3 (--two-pass), nothing to update.")
+		im.findDanglingLinks(sourceIssues, oldToNewIssueNumbers)
+	} else {
+		err = im.updateIssueLinks(ctx, sourceIssues, oldToNewIssueNumbers)
+	}
+	im.writeMapping(*mappingOut, oldToNewIssueNumbers)
+	if err != nil {
+		log.Fatalf("Aborting: %v", err)
+	}
+
+	startPhase(5, "Linking sub-issues to their parents")
+	if err := im.linkSubIssues(ctx, sourceIssues, oldToNewIssueNumbers); err != nil {
+		log.Fatalf("Aborting: %v", err)
+	}
+
+	if im.dryRun {
+		im.writePlan(*planOut)
+		log.Println("\n Dry run complete, no changes were made. ---")
+		return
+	}
+
+	im.summarize(*reportOut)
+	if im.report.hasFailures() {
+		log.Println("\n Migration finished with failures, see the summary above. ---")
+		os.Exit(1)
+	}
+	log.Println("\n All issues created and linked successfully! ---")
 }
 
-func updateIssueLinks(client *github.Client, owner, repo string, issues []Issue, oldToNewIssueNumbers map[int]int) {
-	issueLinkRegex := regexp.MustCompile(`#(\d+)`)
+// summarize ends the last phase, logs the summary of the run, and writes it
+// to reportOut unless that is empty.
+func (im *importer) summarize(reportOut string) {
+	finishPhase()
+	im.report.finishTimings(phaseDurations)
+	im.report.print()
+	if reportOut == "" {
+		return
+	}
+	if err := im.report.write(reportOut); err != nil {
+		log.Printf("Warning: failed to write report to %s: %v\n", reportOut, err)
+	} else {
+		log.Printf("Wrote report to %s", reportOut)
+	}
+}
 
-	for _, sourceIssue := range issues {
-		newlyCreatedNumber, ok := oldToNewIssueNumbers[sourceIssue.Number]
-		if !ok {
-			log.Printf("Skipping body update for old issue #%d as it was not created.", sourceIssue.Number)
-			continue
-		}
+// issuesService is the subset of the GitHub issues API used by the importer.
+// It is satisfied by *github.IssuesService, and can be replaced by a fake to
+// exercise the phases without talking to GitHub.
+type issuesService interface {
+	ListLabels(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.Label, *github.Response, error)
+	CreateLabel(ctx context.Context, owner, repo string, label *github.Label) (*github.Label, *github.Response, error)
+	EditLabel(ctx context.Context, owner, repo, name string, label *github.Label) (*github.Label, *github.Response, error)
+	ListMilestones(ctx context.Context, owner, repo string, opts *github.MilestoneListOptions) ([]*github.Milestone, *github.Response, error)
+	CreateMilestone(ctx context.Context, owner, repo string, milestone *github.Milestone) (*github.Milestone, *github.Response, error)
+	EditMilestone(ctx context.Context, owner, repo string, number int, milestone *github.Milestone) (*github.Milestone, *github.Response, error)
+	ListByRepo(ctx context.Context, owner, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error)
+	Create(ctx context.Context, owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+	Edit(ctx context.Context, owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+	Get(ctx context.Context, owner, repo string, number int) (*github.Issue, *github.Response, error)
+	ListComments(ctx context.Context, owner, repo string, number int, opts *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error)
+	CreateComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	EditComment(ctx context.Context, owner, repo string, commentID int64, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	IsAssignee(ctx context.Context, owner, repo, user string) (bool, *github.Response, error)
+	Lock(ctx context.Context, owner, repo string, number int, opts *github.LockIssueOptions) (*github.Response, error)
+	DeleteLabel(ctx context.Context, owner, repo, name string) (*github.Response, error)
+	DeleteMilestone(ctx context.Context, owner, repo string, number int) (*github.Response, error)
+}
 
-		updatedBody := issueLinkRegex.ReplaceAllStringFunc(sourceIssue.Body, func(match string) string {
-			oldNumStr := strings.TrimPrefix(match, "#")
-			oldNum, _ := strconv.Atoi(oldNumStr)
+var _ issuesService = (*github.IssuesService)(nil)
 
-			if newNum, found := oldToNewIssueNumbers[oldNum]; found {
-				return fmt.Sprintf("#%d", newNum)
-			}
-			return match
-		})
+// importer holds the target repository and the options shared by every phase
+// that talks to the GitHub API.
+type importer struct {
+	// client is used for requests outside the issues API, such as GraphQL.
+	client *github.Client
+	issues issuesService
+	owner  string
+	repo   string
 
-		if updatedBody != sourceIssue.Body {
-			log.Printf("Updating body for new issue #%d (from old #%d)...", newlyCreatedNumber, sourceIssue.Number)
-			updateReq := &github.IssueRequest{Body: &updatedBody}
-			_, _, err := client.Issues.Edit(context.Background(), owner, repo, newlyCreatedNumber, updateReq)
-			if err != nil {
-				log.Printf("Failed to update body for new issue #%d: %v\n", newlyCreatedNumber, err)
-			} else {
-				log.Printf("Success!\n")
-			}
-		}
+	// dryRun logs every mutation instead of sending it to GitHub.
+	dryRun bool
+	// maxRetries bounds how often withRetry repeats a rate-limited call or
+	// one that failed with a transient error.
+	maxRetries int
+	// retryBase and retryMax bound the exponential backoff between retries
+	// of a call that failed with a transient error.
+	retryBase time.Duration
+	retryMax  time.Duration
+	// throttle limits how fast mutating calls are sent.
+	throttle *throttle
+
+	// separateComments posts every source comment individually rather than
+	// as one consolidated comment.
+	separateComments bool
+	// sourceRepo is the owner/name of the repository the issues were
+	// exported from, used for provenance footers. Empty disables them.
+	sourceRepo string
+	// webURL is the web address of the GitHub instance, used to turn
+	// relative links in the source repository into absolute ones.
+	webURL string
+	// preserveAuthors prefixes issue bodies with the original author.
+	preserveAuthors bool
+	// userMap maps lower-cased source logins to target logins for @mention
+	// rewriting.
+	userMap map[string]string
+	// updateLabels edits existing labels whose color or description differ
+	// from the source.
+	updateLabels bool
+	// reconcileDefaults does the same for GitHub's default labels only.
+	reconcileDefaults bool
+	// updateMilestones edits existing milestones whose state differs from
+	// the source.
+	updateMilestones bool
+
+	// postedComments records the comments created on each new issue so
+	// that Phase 4 can rewrite issue links inside them.
+	postedComments map[int][]postedComment
+	// linkTargetsOnly holds the old numbers of source issues that were
+	// mapped onto an issue not created for them under
+	// --skip-existing-titles: an existing issue with the same title, or the
+	// first issue of the run with that title. References to them
+	// are rewritten, but the issue they map to is never edited on their
+	// behalf.
+	linkTargetsOnly map[int]bool
+	// previouslyImported holds the old numbers of source issues skipped
+	// because --mapping-in lists them. Their comments were posted by an
+	// earlier run, so Phase 4 lists them instead of using postedComments.
+	previouslyImported map[int]bool
+
+	// assignable caches whether a login can be assigned to issues in the
+	// target repository.
+	assignable map[string]bool
+
+	// progress numbers the issues created in Phase 3.
+	progress *progress
+
+	// pinnedCount is the number of issues pinned so far during this run.
+	pinnedCount int
+
+	// report collects the outcome of every phase for the final summary.
+	report *report
+
+	// failFast makes the phases return the first mutation error instead of
+	// logging it and moving on.
+	failFast bool
+
+	// concurrency is the number of workers importing issues in Phase 3.
+	concurrency int
+	// mu guards report, postedComments, assignable, and pinnedCount while
+	// issues are imported concurrently.
+	mu sync.Mutex
+
+	// preserveLocks locks new issues whose source issue was locked.
+	preserveLocks bool
+	// preserveTimestamps prefixes issue bodies with the original creation
+	// and last update times.
+	preserveTimestamps bool
+	// migrateReactions replays source reactions on new issues and, with
+	// separateComments, on new comments.
+	migrateReactions bool
+	// skipExistingTitles skips source issues whose title is already used by
+	// an issue in the target repository, reusing that issue's number.
+	skipExistingTitles bool
+	// ignoreTitleCase compares titles case-insensitively for
+	// skipExistingTitles.
+	ignoreTitleCase bool
+
+	// failedIssues are the source issues that could not be created, guarded
+	// by mu.
+	failedIssues []Issue
+
+	// titlePrefix is prepended to the title of every new issue.
+	titlePrefix string
+	// projectID is the node ID of a Projects v2 board that every new issue
+	// is added to. Empty disables it.
+	projectID string
+
+	// typeMappings set the issue type of new issues from their labels, in
+	// declared order.
+	typeMappings []typeMapping
+	// removeTypeLabels leaves out the label an issue's type was taken from.
+	removeTypeLabels bool
+	// issueTypeIDs maps lower-cased issue type names to their node IDs.
+	issueTypeIDs map[string]string
+
+	// oldNumberStyle is where the source issue number is recorded on new
+	// issues: "anchor" for an HTML comment in the body, "title" for a
+	// suffix of the title, or empty for nowhere.
+	oldNumberStyle string
+
+	// consolidatedHeader opens the consolidated comment; empty leaves it
+	// out.
+	consolidatedHeader string
+	// commentAuthorTemplate renders the attribution line above every
+	// migrated comment. Nil uses the default format.
+	commentAuthorTemplate *template.Template
+
+	// preserveNumbers creates every issue under its source number, filling
+	// gaps with closed placeholder issues.
+	preserveNumbers bool
+	// nextNumber is the number the next issue created in the target
+	// repository will get, tracked for preserveNumbers.
+	nextNumber int
+
+	// attachmentsBranch and attachmentsPath are where --copy-attachments
+	// stores the copied attachments in the target repository.
+	attachmentsBranch string
+	attachmentsPath   string
+
+	// targetMilestone, when set, is the milestone every new issue is put
+	// in, replacing the source milestones.
+	targetMilestone string
+	// addLabels are added to every new issue on top of its source labels.
+	addLabels []string
+	// teamMention is the @org/slug mention of the --assign-team team posted
+	// on every new issue. Empty disables it.
+	teamMention string
+	// commentBatchSize is the most source comments put in one consolidated
+	// comment; 0 puts them all in one.
+	commentBatchSize int
+	// twoPass creates every issue with a placeholder body first and writes
+	// the body, with its links already rewritten, and the comments in a
+	// second pass, replacing Phase 4.
+	twoPass bool
+	// createdIssues are the issues created in the first pass of twoPass,
+	// keyed by source number and guarded by mu.
+	createdIssues map[int]*github.Issue
+	// ownerType is "user" or "org" for the owner of the target repository,
+	// or "auto" until resolveOwnerType has looked it up.
+	ownerType string
+	// collapseComments wraps the consolidated comment in collapsible
+	// <details> blocks: "comment" for one per comment, "author" for one per
+	// author, or empty for none.
+	collapseComments string
+	// plan records what a dry run would create, for --plan-out. Nil unless
+	// requested.
+	plan *plan
+}
+
+// postedComment is a comment created by the importer, along with the body it
+// was created with.
+type postedComment struct {
+	id   int64
+	body string
+}
+
+// writeMapping saves the old-to-new issue number mapping as a JSON object so a
+// run can be audited afterwards. It is a no-op when path is empty, and during
+// a dry run, where the numbers are only simulated.
+func (im *importer) writeMapping(path string, oldToNewIssueNumbers map[int]int) {
+	if path == "" {
+		return
+	}
+	if im.dryRun {
+		log.Printf("[dry-run] Would write issue number mapping to %s", path)
+		return
+	}
+
+	data, err := json.MarshalIndent(oldToNewIssueNumbers, "", "  ")
+	if err != nil {
+		log.Printf("Warning: failed to encode issue number mapping: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Printf("Warning: failed to write issue number mapping to %s: %v\n", path, err)
+		return
+	}
+	log.Printf("Wrote mapping for %d issues to %s", len(oldToNewIssueNumbers), path)
+}
+
+// artifactPath returns where a run artifact is written with --output-dir: in
+// dir under defaultName when path is empty, in dir under path when path is
+// relative, and at path itself when it is absolute.
+func artifactPath(dir, path, defaultName string) string {
+	switch {
+	case path == "":
+		return filepath.Join(dir, defaultName)
+	case filepath.IsAbs(path):
+		return path
+	default:
+		return filepath.Join(dir, path)
+	}
+}
+
+// validRepoName reports whether name has the form owner/repo.
+func validRepoName(name string) bool {
+	owner, repo, ok := strings.Cut(name, "/")
+	return ok && owner != "" && repo != "" && !strings.Contains(repo, "/")
+}
+
+// resolveToken returns the GitHub token from the --token flag, the file named
+// by --token-file, or the GITHUB_TOKEN environment variable, in that order.
+// Trailing whitespace is trimmed from file contents since mounted secrets
+// often end in a newline.
+func resolveToken(token, tokenFile string) (string, error) {
+	if token != "" {
+		return token, nil
+	}
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", err
+		}
+		if fileToken := strings.TrimSpace(string(data)); fileToken != "" {
+			return fileToken, nil
+		}
+	}
+	return os.Getenv("GITHUB_TOKEN"), nil
+}
+
+// newGitHubClient returns a client authenticating with tokenSource, against
+// the GitHub Enterprise Server at baseURL unless that is empty. With --verbose
+// every request is logged.
+func newGitHubClient(tokenSource oauth2.TokenSource, baseURL string) (*github.Client, error) {
+	httpClient := oauth2.NewClient(context.Background(), tokenSource)
+	if currentLogLevel >= levelVerbose {
+		httpClient.Transport = &loggingTransport{base: httpClient.Transport}
+	}
+	client := github.NewClient(httpClient)
+	if baseURL == "" {
+		return client, nil
+	}
+	return client.WithEnterpriseURLs(baseURL, baseURL)
+}
+
+// validateBaseURL checks that raw is an absolute http(s) URL before it is
+// handed to the GitHub client.
+func validateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+// writeFailures saves the source issues that could not be created, and those
+// that lost some of their comments, as a JSON array in the same format as the
+// input, so that the file can be fixed up and passed to --retry-from.
+func (im *importer) writeFailures(path string) {
+	if path == "" || im.dryRun {
+		return
+	}
+	if err := writeIssues(path, im.failedIssues); err != nil {
+		log.Printf("Warning: failed to write failed issues to %s: %v\n", path, err)
+		return
+	}
+	log.Printf("Wrote %d failed issues to %s", len(im.failedIssues), path)
+}
+
+// openInput opens the file at path, or returns standard input when path is
+// "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// loadIssues reads and merges the issue arrays in the given files, which are
+// in the given input format. The files are decoded one issue at a time, so
+// the raw JSON is never held in memory as a whole, but memory use is not
+// bounded: every decoded issue is returned, since the issues are sorted and
+// the phases that follow need all of them. An issue number that appears in
+// more than one file is only kept the first time.
+func loadIssues(paths []string, format string) ([]Issue, error) {
+	var merged []Issue
+	seen := make(map[int]bool)
+	for _, path := range paths {
+		input, err := openInput(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		duplicates := 0
+		err = decodeIssues(input, format, func(issue Issue) {
+			if seen[issue.Number] {
+				duplicates++
+				return
+			}
+			seen[issue.Number] = true
+			merged = append(merged, issue)
+		})
+		input.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling %s: %v", path, err)
+		}
+		if duplicates > 0 {
+			log.Printf("Ignoring %d issues in %s that were already read from an earlier file.", duplicates, path)
+		}
+	}
+	return merged, nil
+}
+
+// readMapping loads an old-to-new issue number mapping written by writeMapping.
+func readMapping(path string) (map[int]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	oldToNewIssueNumbers := make(map[int]int)
+	if err := json.Unmarshal(data, &oldToNewIssueNumbers); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return oldToNewIssueNumbers, nil
+}
+
+// findLablesAndMilestones collects the labels and milestones used by issues.
+// Milestones are keyed by milestoneKey, and the first spelling of a title that
+// is encountered wins.
+func findLablesAndMilestones(issues []Issue) (map[string]Label, map[string]Milestone) {
+	uniqueLabels := make(map[string]Label)
+	uniqueMilestones := make(map[string]Milestone)
+
+	for _, issue := range issues {
+		for _, label := range issue.Labels {
+			uniqueLabels[label.Name] = label
+		}
+		if issue.Milestone != nil {
+			key := milestoneKey(issue.Milestone.Title)
+			if _, seen := uniqueMilestones[key]; !seen {
+				uniqueMilestones[key] = *issue.Milestone
+			}
+		}
+	}
+	log.Printf("Found %d unique labels and %d unique milestones.\n", len(uniqueLabels), len(uniqueMilestones))
+
+	return uniqueLabels, uniqueMilestones
+}
+
+func (im *importer) createLabels(ctx context.Context, labels map[string]Label) error {
+	existingLabelsByName, err := im.existingLabels(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Iterate in name order so that logs and creation order are reproducible.
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		label := labels[name]
+		label.Color = normalizeLabelColor(name, label.Color)
+		if existing, ok := existingLabelsByName[strings.ToLower(name)]; ok {
+			if im.updateLabels || (im.reconcileDefaults && slices.Contains(githubDefaultLabels, strings.ToLower(name))) {
+				if err := im.updateLabel(ctx, existing, label); err != nil {
+					return err
+				}
+			}
+			im.report.LabelsSkipped = append(im.report.LabelsSkipped, name)
+			continue
+		}
+
+		if im.dryRun {
+			infof("[dry-run] Would create label: [%s]", name)
+			if im.plan != nil {
+				im.plan.LabelsToCreate = append(im.plan.LabelsToCreate, name)
+			}
+			continue
+		}
+		infof("Creating label: [%s]", name)
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			_, resp, err := im.issues.CreateLabel(ctx, im.owner, im.repo, &github.Label{
+				Name:        &label.Name,
+				Color:       &label.Color,
+				Description: &label.Description,
+			})
+			return resp, err
+		})
+		if err != nil {
+			eventf(levelQuiet, logFields{Action: "label_failed", Name: name, Error: err.Error()}, "Warning: failed to create label [%s]: %v\n", name, err)
+			im.report.LabelsFailed = append(im.report.LabelsFailed, name)
+			if im.failFast {
+				return fmt.Errorf("failed to create label [%s]: %v", name, err)
+			}
+		} else {
+			im.report.LabelsCreated = append(im.report.LabelsCreated, name)
+		}
+	}
+
+	return nil
+}
+
+// createMilestones creates the milestones that do not exist in the target
+// repository yet and returns the number of every milestone keyed by
+// milestoneKey. Existing milestones are matched case-insensitively, keeping
+// their title as it is.
+func (im *importer) createMilestones(ctx context.Context, milestones map[string]Milestone) (map[string]int, error) {
+	milestoneTitleToNumber := make(map[string]int)
+	existingMilestonesByTitle := make(map[string]*github.Milestone)
+	// simulatedNumber hands out milestone numbers during a dry run so that
+	// issues can still be matched to the milestones that would be created.
+	simulatedNumber := 0
+	listOpts := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		existingMilestones, resp, err := im.issues.ListMilestones(ctx, im.owner, im.repo, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch existing milestones: %v", err)
+		}
+		for _, m := range existingMilestones {
+			simulatedNumber = max(simulatedNumber, m.GetNumber())
+			key := milestoneKey(m.GetTitle())
+			if _, seen := existingMilestonesByTitle[key]; seen {
+				continue
+			}
+			milestoneTitleToNumber[key] = m.GetNumber()
+			existingMilestonesByTitle[key] = m
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(milestones)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		milestone := milestones[key]
+		title := strings.TrimSpace(milestone.Title)
+		if existing, exists := existingMilestonesByTitle[key]; exists {
+			if im.updateMilestones {
+				if err := im.updateMilestoneState(ctx, existing, milestone); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if im.dryRun {
+			simulatedNumber++
+			infof("[dry-run] Would create %s milestone: %s", milestone.state(), title)
+			milestoneTitleToNumber[key] = simulatedNumber
+			if im.plan != nil {
+				im.plan.MilestonesToCreate = append(im.plan.MilestonesToCreate, plannedMilestone{Title: title, State: milestone.state(), Number: simulatedNumber})
+			}
+			continue
+		}
+
+		infof("Creating milestone: %s", title)
+
+		newMilestoneReq := &github.Milestone{
+			Title:       &title,
+			Description: &milestone.Description,
+			State:       github.Ptr(milestone.state()),
+		}
+
+		if milestone.DueOn != nil && strings.TrimSpace(*milestone.DueOn) != "" {
+			parsedTime, err := parseDate(*milestone.DueOn)
+			if err != nil {
+				log.Printf("Warning: could not parse due date for milestone '%s': %v. Creating without due date.", title, err)
+			} else {
+				newMilestoneReq.DueOn = &github.Timestamp{Time: parsedTime}
+			}
+		}
+
+		var createdMilestone *github.Milestone
+		err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+			createdMilestone, resp, err = im.issues.CreateMilestone(ctx, im.owner, im.repo, newMilestoneReq)
+			return resp, err
+		})
+		if err != nil {
+			eventf(levelQuiet, logFields{Action: "milestone_failed", Name: title, Error: err.Error()}, "Warning: failed to create milestone '%s': %v\n", title, err)
+			im.report.MilestonesFailed = append(im.report.MilestonesFailed, title)
+			if im.failFast {
+				return nil, fmt.Errorf("failed to create milestone '%s': %v", title, err)
+			}
+		} else {
+			milestoneTitleToNumber[key] = createdMilestone.GetNumber()
+			im.report.MilestonesCreated = append(im.report.MilestonesCreated, title)
+		}
+	}
+
+	return milestoneTitleToNumber, nil
+}
+
+// createIssueAndComment creates every source issue that is not already part of
+// previousMapping and returns the combined old-to-new issue number mapping.
+// With --skip-existing-titles, issues whose title is already used in the
+// target repository are mapped to the existing issue instead of being created.
+// Issues are imported by im.concurrency workers. It stops when ctx is done, or
+// with --fail-fast at the first failure, still returning the mapping built so
+// far alongside the error.
+func (im *importer) createIssueAndComment(ctx context.Context, issues []Issue, milestoneTitleToNum map[string]int, previousMapping map[int]int) (map[int]int, error) {
+	oldToNewIssueNumbers := make(map[int]int, len(previousMapping))
+	// simulatedNumber stands in for the numbers GitHub would assign during a
+	// dry run, so the Phase 4 link-rewrite plan can still be printed.
+	simulatedNumber := 0
+	for oldNum, newNum := range previousMapping {
+		oldToNewIssueNumbers[oldNum] = newNum
+		simulatedNumber = max(simulatedNumber, newNum)
+	}
+
+	var existingTitles map[string]int
+	if im.skipExistingTitles {
+		var err error
+		existingTitles, err = im.existingIssueTitles(ctx)
+		if err != nil {
+			return oldToNewIssueNumbers, err
+		}
+		log.Printf("Found %d distinct issue titles in the target repository.", len(existingTitles))
+	}
+
+	// firstWithTitle records, by titleKey, the first pending issue with each
+	// title, so repeated titles within the input are noticed. With
+	// --skip-existing-titles the repeats are skipped like titles that
+	// already exist, and duplicateOf maps each onto that first issue.
+	firstWithTitle := make(map[string]int)
+	duplicateOf := make(map[int]int)
+	pending := make([]Issue, 0, len(issues))
+	var resumed []Issue
+	for _, issue := range issues {
+		if issue.ImportedAs != 0 {
+			resumed = append(resumed, issue)
+			continue
+		}
+		if newNum, ok := previousMapping[issue.Number]; ok {
+			eventf(levelNormal, logFields{Action: "issue_skipped", OldNumber: issue.Number, NewNumber: newNum}, "Skipping old issue #%d, already imported as #%d.", issue.Number, newNum)
+			im.previouslyImported[issue.Number] = true
+			im.report.IssuesSkipped++
+			continue
+		}
+		if newNum, ok := existingTitles[im.titleKey(im.issueTitle(issue))]; ok {
+			eventf(levelNormal, logFields{Action: "issue_skipped", OldNumber: issue.Number, NewNumber: newNum}, "Skipping old issue #%d, its title \"%s\" is already used by #%d.", issue.Number, im.issueTitle(issue), newNum)
+			oldToNewIssueNumbers[issue.Number] = newNum
+			im.linkTargetsOnly[issue.Number] = true
+			im.report.IssuesSkipped++
+			continue
+		}
+		key := im.titleKey(im.issueTitle(issue))
+		if first, ok := firstWithTitle[key]; ok {
+			if im.skipExistingTitles {
+				eventf(levelNormal, logFields{Action: "issue_skipped", OldNumber: issue.Number}, "Skipping old issue #%d, its title \"%s\" is already used by old issue #%d in this run.", issue.Number, im.issueTitle(issue), first)
+				duplicateOf[issue.Number] = first
+				im.linkTargetsOnly[issue.Number] = true
+				im.report.IssuesSkipped++
+				continue
+			}
+			infof("Old issue #%d has the same title \"%s\" as old issue #%d, creating both.", issue.Number, im.issueTitle(issue), first)
+		} else {
+			firstWithTitle[key] = issue.Number
+		}
+		pending = append(pending, issue)
+	}
+	// The skipped repeats share the new number of their first issue once
+	// it is known.
+	defer func() {
+		for oldNum, first := range duplicateOf {
+			if newNum, ok := oldToNewIssueNumbers[first]; ok {
+				oldToNewIssueNumbers[oldNum] = newNum
+			} else {
+				log.Printf("Old issue #%d was not imported, since old issue #%d with the same title was not created.", oldNum, first)
+			}
+		}
+	}()
+
+	for _, issue := range resumed {
+		oldToNewIssueNumbers[issue.Number] = issue.ImportedAs
+		comments := nonEmptyComments(issue.Comments)
+		if im.dryRun {
+			infof("[dry-run] Would post the %d missing comments of old issue #%d on #%d", len(comments), issue.Number, issue.ImportedAs)
+			continue
+		}
+		infof("Posting the %d missing comments of old issue #%d on #%d", len(comments), issue.Number, issue.ImportedAs)
+		if err := im.postSeparateComments(ctx, issue, issue.ImportedAs, comments); err != nil {
+			return oldToNewIssueNumbers, err
+		}
+	}
+
+	if im.preserveNumbers {
+		// Placeholders only fill gaps below the next issue, so the issues
+		// have to be created in number order.
+		slices.SortFunc(pending, func(a, b Issue) int { return a.Number - b.Number })
+	}
+
+	if im.dryRun {
+		for _, issue := range pending {
+			simulatedNumber++
+			if im.preserveNumbers {
+				if err := im.fillNumberGap(ctx, issue.Number); err != nil {
+					return oldToNewIssueNumbers, err
+				}
+				simulatedNumber = issue.Number
+				im.nextNumber = issue.Number + 1
+			}
+			oldToNewIssueNumbers[issue.Number] = simulatedNumber
+			im.planIssue(ctx, issue, simulatedNumber, milestoneTitleToNum)
+		}
+		return oldToNewIssueNumbers, nil
+	}
+
+	im.progress = newProgress(len(issues), len(issues)-len(pending))
+	var mu sync.Mutex
+	err := im.forEachIssue(ctx, pending, func(issue Issue) error {
+		started := time.Now()
+		newNum, err := im.importIssue(ctx, issue, milestoneTitleToNum)
+		im.progress.done()
+		if newNum != 0 {
+			im.mu.Lock()
+			im.report.issueTime += time.Since(started)
+			im.mu.Unlock()
+			mu.Lock()
+			oldToNewIssueNumbers[issue.Number] = newNum
+			mu.Unlock()
+		}
+		return err
+	})
+	if err == nil && im.twoPass {
+		err = im.completeTwoPass(ctx, pending, oldToNewIssueNumbers)
+	}
+	return oldToNewIssueNumbers, err
+}
+
+// forEachIssue calls fn for every issue from im.concurrency workers. It stops
+// handing out issues once fn returns an error or ctx is done, waits for the
+// calls in flight, and returns the first error.
+func (im *importer) forEachIssue(ctx context.Context, issues []Issue, fn func(Issue) error) error {
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan Issue)
+	for range max(im.concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for issue := range jobs {
+				err := fn(issue)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, issue := range issues {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = ctx.Err()
+		}
+		stop := firstErr != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+		jobs <- issue
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// issueRequest builds the request that creates the new issue for a source
+// issue. If the body is too long for GitHub it is truncated, and the part that
+// did not fit is returned as overflow to be posted as a comment.
+func (im *importer) issueRequest(ctx context.Context, issue Issue, milestoneTitleToNum map[string]int) (*github.IssueRequest, string) {
+	labelNames := im.issueLabels(issue)
+	head, overflow := im.splitSourceBody(issue)
+	body := im.issueBody(issue, head)
+	title := im.issueTitle(issue)
+	if overflow != "" {
+		overflow = mapMentions(im.absolutizeURLs(overflow), im.userMap)
+	}
+	newIssueRequest := &github.IssueRequest{
+		Title:  &title,
+		Body:   &body,
+		Labels: &labelNames,
+	}
+
+	if im.targetMilestone != "" {
+		if newMilestoneNum, ok := milestoneTitleToNum[milestoneKey(im.targetMilestone)]; ok {
+			newIssueRequest.Milestone = &newMilestoneNum
+		}
+	} else if issue.Milestone != nil {
+		if newMilestoneNum, ok := milestoneTitleToNum[milestoneKey(issue.Milestone.Title)]; ok {
+			newIssueRequest.Milestone = &newMilestoneNum
+		} else {
+			log.Printf("Warning: milestone '%s' of old issue #%d does not exist in the target repository, creating the issue without it.", issue.Milestone.Title, issue.Number)
+		}
+	}
+
+	if len(issue.Assignees) > 0 {
+		assignees := im.assignableLogins(ctx, issue.Assignees)
+		newIssueRequest.Assignees = &assignees
+	}
+
+	return newIssueRequest, overflow
+}
+
+// issueLabels returns the names of the labels a new issue is created with: its
+// source labels, without the one its type was taken from when
+// --remove-type-labels is set, and the --add-label labels. They are sorted so
+// that the created issues can be compared reliably.
+func (im *importer) issueLabels(issue Issue) []string {
+	var typeLabel string
+	if im.removeTypeLabels {
+		_, typeLabel = im.issueType(issue)
+	}
+	labelNames := make([]string, 0)
+	for _, label := range issue.Labels {
+		if typeLabel != "" && label.Name == typeLabel {
+			continue
+		}
+		labelNames = append(labelNames, label.Name)
+	}
+	for _, name := range im.addLabels {
+		if !slices.Contains(labelNames, name) {
+			labelNames = append(labelNames, name)
+		}
+	}
+	slices.Sort(labelNames)
+	return labelNames
+}
+
+// includeAddedLabels adds the --add-label labels that no source issue
+// carries to labels, with the default color, so that they are created once in
+// Phase 2 along with the source labels.
+func (im *importer) includeAddedLabels(labels map[string]Label) {
+	for _, name := range im.addLabels {
+		if _, ok := labels[name]; !ok {
+			labels[name] = Label{Name: name, Color: defaultLabelColor}
+		}
+	}
+}
+
+// issueMilestone returns the title of the milestone the import puts issue
+// in, or "" for none.
+func (im *importer) issueMilestone(issue Issue) string {
+	if im.targetMilestone != "" {
+		return im.targetMilestone
+	}
+	if issue.Milestone != nil {
+		return issue.Milestone.Title
+	}
+	return ""
+}
+
+// planIssue logs what importIssue would do for a source issue during a dry
+// run, using simulatedNumber in place of the number GitHub would assign.
+func (im *importer) planIssue(ctx context.Context, issue Issue, simulatedNumber int, milestoneTitleToNum map[string]int) {
+	newIssueRequest, overflow := im.issueRequest(ctx, issue, milestoneTitleToNum)
+	eventf(levelNormal, logFields{Action: "issue_planned", OldNumber: issue.Number, NewNumber: simulatedNumber},
+		"[dry-run] Would create issue #%d for: \"%s\" (labels: %v, assignees: %v, comments: %d)", simulatedNumber, newIssueRequest.GetTitle(), newIssueRequest.GetLabels(), newIssueRequest.GetAssignees(), len(nonEmptyComments(issue.Comments)))
+	if im.plan != nil {
+		planned := plannedIssue{
+			OldNumber: issue.Number,
+			NewNumber: simulatedNumber,
+			Title:     newIssueRequest.GetTitle(),
+			Labels:    newIssueRequest.GetLabels(),
+			Comments:  im.expectedComments(issue),
+		}
+		if newIssueRequest.Milestone != nil {
+			planned.Milestone = im.issueMilestone(issue)
+		}
+		im.plan.Issues = append(im.plan.Issues, planned)
+	}
+	if typeName, _ := im.issueType(issue); typeName != "" {
+		infof("[dry-run] Would set type of issue #%d to %s", simulatedNumber, typeName)
+	}
+	if im.projectID != "" {
+		infof("[dry-run] Would add issue #%d to project %s", simulatedNumber, im.projectID)
+	}
+	if overflow != "" {
+		infof("[dry-run] Would truncate the body of issue #%d and post the remaining %d characters as a comment", simulatedNumber, utf8.RuneCountInString(overflow))
+	}
+	if im.teamMention != "" {
+		infof("[dry-run] Would mention team %s on issue #%d", im.teamMention, simulatedNumber)
+	}
+	if issue.isClosed() {
+		infof("[dry-run] Would close issue #%d as %s", simulatedNumber, issue.closeReason())
+	}
+	if im.migrateReactions {
+		if contents := reactionsToAdd(issue.Reactions); len(contents) > 0 {
+			infof("[dry-run] Would add reactions %v to issue #%d", contents, simulatedNumber)
+		}
+	}
+	if im.preserveLocks && issue.Locked {
+		infof("[dry-run] Would lock issue #%d (reason: %q)", simulatedNumber, issue.lockReason())
+	}
+	if issue.IsPinned {
+		infof("[dry-run] Would pin issue #%d", simulatedNumber)
+	}
+}
+
+// importIssue creates the new issue for a source issue, posts its comments,
+// and closes, locks, and pins it to match the source. With --two-pass it only
+// creates the issue, with a placeholder body, and leaves the rest to
+// completeTwoPass. It returns the new issue number, or 0 if the issue could
+// not be created. Errors are only returned with --fail-fast. It is safe to
+// call from several workers at once.
+func (im *importer) importIssue(ctx context.Context, issue Issue, milestoneTitleToNum map[string]int) (int, error) {
+	newIssueRequest, overflow := im.issueRequest(ctx, issue, milestoneTitleToNum)
+	if im.twoPass {
+		// Only the parts of the body that hold no links are written now;
+		// the rest follows in the second pass.
+		placeholder := im.issueBody(issue, "")
+		newIssueRequest.Body = &placeholder
+	}
+
+	if im.preserveNumbers {
+		if err := im.fillNumberGap(ctx, issue.Number); err != nil {
+			return 0, err
+		}
+	}
+
+	infof("%s Creating issue for: \"%s\"...%s", im.progress.next(), newIssueRequest.GetTitle(), im.progress.eta())
+	var createdIssue *github.Issue
+	err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+		createdIssue, resp, err = im.issues.Create(ctx, im.owner, im.repo, newIssueRequest)
+		return resp, err
+	})
+	if err != nil {
+		eventf(levelQuiet, logFields{Action: "issue_failed", OldNumber: issue.Number, Error: err.Error()}, "Failed to create issue \"%s\": %v", issue.Title, err)
+		im.mu.Lock()
+		im.report.IssuesFailed = append(im.report.IssuesFailed, issueFailure{Number: issue.Number, Title: issue.Title, Error: err.Error()})
+		im.failedIssues = append(im.failedIssues, issue)
+		im.mu.Unlock()
+		// With --preserve-numbers, a failed issue would shift every later
+		// issue off its number, so the run stops.
+		if im.failFast || im.preserveNumbers {
+			return 0, fmt.Errorf("failed to create issue \"%s\": %v", issue.Title, err)
+		}
+		return 0, nil
+	}
+
+	newlyCreatedNumber := createdIssue.GetNumber()
+	if im.preserveNumbers {
+		if newlyCreatedNumber != issue.Number {
+			return newlyCreatedNumber, fmt.Errorf("old issue #%d was created as #%d, issue numbers can no longer be preserved", issue.Number, newlyCreatedNumber)
+		}
+		im.nextNumber = newlyCreatedNumber + 1
+	}
+	eventf(levelNormal, logFields{Action: "issue_created", OldNumber: issue.Number, NewNumber: newlyCreatedNumber}, "Created issue #%d from old #%d", newlyCreatedNumber, issue.Number)
+	im.mu.Lock()
+	im.report.IssuesCreated++
+	im.mu.Unlock()
+
+	if im.twoPass {
+		im.mu.Lock()
+		im.createdIssues[issue.Number] = createdIssue
+		im.mu.Unlock()
+		return newlyCreatedNumber, nil
+	}
+	return newlyCreatedNumber, im.completeIssue(ctx, issue, createdIssue, overflow)
+}
+
+// completeIssue adds everything but the title, labels, milestone, assignees,
+// and body to a new issue: its type, project, and reactions, the overflow of
+// a truncated body, the comments, and the team mention, and then closes,
+// locks, and pins it to match the source. Errors are only returned with
+// --fail-fast.
+func (im *importer) completeIssue(ctx context.Context, issue Issue, createdIssue *github.Issue, overflow string) error {
+	newlyCreatedNumber := createdIssue.GetNumber()
+
+	if typeName, _ := im.issueType(issue); typeName != "" {
+		if err := im.setIssueType(ctx, createdIssue, typeName); err != nil {
+			return err
+		}
+	}
+
+	if im.projectID != "" {
+		if err := im.addToProject(ctx, createdIssue); err != nil {
+			return err
+		}
+	}
+
+	if im.migrateReactions {
+		if err := im.addIssueReactions(ctx, newlyCreatedNumber, issue.Reactions); err != nil {
+			return err
+		}
+	}
+
+	if overflow != "" {
+		infof("Body of issue #%d exceeded %d characters, posting the remainder as a comment", newlyCreatedNumber, maxBodyLength)
+		if _, err := im.createComment(ctx, newlyCreatedNumber, overflow); err != nil {
+			log.Printf("Failed to post the remainder of the body of issue #%d: %v\n", newlyCreatedNumber, err)
+			if im.failFast {
+				return fmt.Errorf("failed to post the remainder of the body of issue #%d: %v", newlyCreatedNumber, err)
+			}
+		}
+	}
+
+	if comments := nonEmptyComments(issue.Comments); len(comments) > 0 {
+		var err error
+		if im.separateComments {
+			err = im.postSeparateComments(ctx, issue, newlyCreatedNumber, comments)
+		} else {
+			err = im.postConsolidatedComment(ctx, newlyCreatedNumber, comments)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if im.teamMention != "" {
+		if err := im.mentionTeam(ctx, newlyCreatedNumber); err != nil {
+			return err
+		}
+	}
+
+	// Close only after the comments are posted so they land on the issue
+	// regardless of its final state.
+	if issue.isClosed() {
+		infof("Closing issue #%d as %s to match the source state", newlyCreatedNumber, issue.closeReason())
+		closeReq := &github.IssueRequest{
+			State:       github.Ptr("closed"),
+			StateReason: github.Ptr(issue.closeReason()),
+		}
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			_, resp, err := im.issues.Edit(ctx, im.owner, im.repo, newlyCreatedNumber, closeReq)
+			return resp, err
+		})
+		if err != nil {
+			log.Printf("Failed to close issue #%d: %v\n", newlyCreatedNumber, err)
+			im.mu.Lock()
+			im.report.EditsFailed++
+			im.mu.Unlock()
+			if im.failFast {
+				return fmt.Errorf("failed to close issue #%d: %v", newlyCreatedNumber, err)
+			}
+		}
+	}
+
+	if im.preserveLocks && issue.Locked {
+		if err := im.lockIssue(ctx, newlyCreatedNumber, issue.lockReason()); err != nil {
+			return err
+		}
+	}
+
+	if issue.IsPinned {
+		if err := im.pinIssue(ctx, createdIssue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockIssue locks a new issue with the given reason, which may be empty. It is
+// called once the comments are posted, since a locked issue only accepts
+// comments from collaborators. A failure is only returned with --fail-fast.
+func (im *importer) lockIssue(ctx context.Context, issueNumber int, reason string) error {
+	infof("Locking issue #%d to match the source (reason: %q)", issueNumber, reason)
+	err := im.withRetry(ctx, func() (*github.Response, error) {
+		return im.issues.Lock(ctx, im.owner, im.repo, issueNumber, &github.LockIssueOptions{LockReason: reason})
+	})
+	if err != nil {
+		log.Printf("Failed to lock issue #%d: %v\n", issueNumber, err)
+		im.mu.Lock()
+		im.report.EditsFailed++
+		im.mu.Unlock()
+		if im.failFast {
+			return fmt.Errorf("failed to lock issue #%d: %v", issueNumber, err)
+		}
+	}
+	return nil
+}
+
+// issueTitle returns the title a new issue is created with. With
+// --old-number-style title, the source number is appended to it.
+func (im *importer) issueTitle(issue Issue) string {
+	if im.oldNumberStyle == "title" {
+		return fmt.Sprintf("%s%s (was #%d)", im.titlePrefix, issue.Title, issue.Number)
+	}
+	return im.titlePrefix + issue.Title
+}
+
+// issueBody returns the body a new issue is created with: text, which is the
+// source body or the head of it returned by splitSourceBody, optionally
+// preceded by the original author and followed by a provenance footer when
+// the source repository is known. With --preserve-timestamps, the original
+// creation and update times are noted below the author line, and with
+// --old-number-style anchor, the source number is recorded in a trailing HTML
+// comment. Phase 4 rebuilds it the same way from the text with its links
+// rewritten, so the footer and the anchor are never rewritten.
+func (im *importer) issueBody(issue Issue, text string) string {
+	// The parts are joined with blank lines, so an empty or whitespace-only
+	// source body leaves neither a dangling footer nor trailing blank lines.
+	var parts []string
+	if im.preserveAuthors {
+		parts = append(parts, fmt.Sprintf("_Originally opened by %s_", issue.Author.displayName()))
+	}
+	if im.preserveTimestamps {
+		if provenance := timestampProvenance(issue); provenance != "" {
+			parts = append(parts, provenance)
+		}
+	}
+	if strings.TrimSpace(text) != "" {
+		parts = append(parts, im.absolutizeURLs(text))
+	}
+	if im.sourceRepo != "" {
+		parts = append(parts, fmt.Sprintf("_Migrated from %s#%d_", im.sourceRepo, issue.Number))
+	}
+	if im.oldNumberStyle == "anchor" {
+		parts = append(parts, fmt.Sprintf("<!-- original: #%d -->", issue.Number))
+	}
+	return mapMentions(strings.Join(parts, "\n\n"), im.userMap)
+}
+
+// timestampProvenance returns a line noting when the source issue was opened
+// and last updated, leaving out any time that is missing or unparsable. It
+// returns "" when neither time is known.
+func timestampProvenance(issue Issue) string {
+	format := func(value string) string {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	}
+
+	createdAt, updatedAt := format(issue.CreatedAt), format(issue.UpdatedAt)
+	switch {
+	case createdAt != "" && updatedAt != "":
+		return fmt.Sprintf("_Originally opened on %s, last updated on %s_", createdAt, updatedAt)
+	case createdAt != "":
+		return fmt.Sprintf("_Originally opened on %s_", createdAt)
+	case updatedAt != "":
+		return fmt.Sprintf("_Last updated on %s_", updatedAt)
+	}
+	return ""
+}
+
+// assignableLogins returns the logins of the given users that can be assigned
+// in the target repository. GitHub rejects or silently drops assignees that
+// are not collaborators, so those are left out and logged instead. The check
+// is retried like any other call; a login that still could not be checked is
+// left out of this issue only, and checked again for the next one.
+func (im *importer) assignableLogins(ctx context.Context, users []User) []string {
+	logins := make([]string, 0, len(users))
+	for _, user := range users {
+		im.mu.Lock()
+		ok, checked := im.assignable[user.Login]
+		im.mu.Unlock()
+		if !checked {
+			err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+				ok, resp, err = im.issues.IsAssignee(ctx, im.owner, im.repo, user.Login)
+				return resp, err
+			})
+			if err != nil {
+				// The answer is unknown, so it is not cached and the next
+				// issue asks again.
+				log.Printf("Warning: could not check whether @%s is assignable, leaving them out of this issue: %v\n", user.Login, err)
+				continue
+			}
+			im.mu.Lock()
+			im.assignable[user.Login] = ok
+			im.mu.Unlock()
+		}
+
+		if !ok {
+			log.Printf("Dropping assignee @%s, who cannot be assigned in %s/%s", user.Login, im.owner, im.repo)
+			continue
+		}
+		logins = append(logins, user.Login)
+	}
+	return logins
+}
+
+// nonEmptyComments returns the comments whose body is not blank; blank ones
+// are not migrated.
+func nonEmptyComments(comments []Comment) []Comment {
+	kept := make([]Comment, 0, len(comments))
+	for _, comment := range comments {
+		if strings.TrimSpace(comment.Body) != "" {
+			kept = append(kept, comment)
+		}
+	}
+	return kept
+}
+
+// defaultConsolidatedHeader opens the consolidated comment unless
+// --comment-header replaces it.
+const defaultConsolidatedHeader = "### Comments from original issue:"
+
+// defaultCommentAuthorFormat is the --comment-author-format used when none is
+// given.
+const defaultCommentAuthorFormat = "**Comment from {{.Name}}{{if .Date}} on {{.Date}}{{end}}:**"
+
+var defaultCommentAuthorTemplate = template.Must(parseCommentAuthorFormat(defaultCommentAuthorFormat))
+
+// commentAuthorData is what a --comment-author-format template is rendered
+// with. Author is the original login and Name the User.displayName of the
+// author. Date is the original posting date as YYYY-MM-DD, or empty when it
+// is not known.
+type commentAuthorData struct {
+	Author string
+	Name   string
+	Date   string
+}
+
+// parseCommentAuthorFormat parses a --comment-author-format template and
+// checks that it renders, so mistakes are reported before any changes are
+// made.
+func parseCommentAuthorFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("comment-author-format").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, commentAuthorData{Author: "octocat", Name: "@octocat", Date: "2024-05-01"}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// newCommentAuthorData returns the author and posting date of comment.
+func newCommentAuthorData(comment Comment) commentAuthorData {
+	data := commentAuthorData{Author: comment.Author.Login, Name: comment.Author.displayName()}
+	if createdAt, err := time.Parse(time.RFC3339, comment.CreatedAt); err == nil {
+		data.Date = createdAt.Format(time.DateOnly)
+	}
+	return data
+}
+
+// commentHeader returns the attribution line placed above a migrated comment,
+// rendered from --comment-author-format with the original author and, when it
+// is known, the posting date.
+func (im *importer) commentHeader(comment Comment) string {
+	data := newCommentAuthorData(comment)
+	tmpl := im.commentAuthorTemplate
+	if tmpl == nil {
+		tmpl = defaultCommentAuthorTemplate
+	}
+	var header strings.Builder
+	if err := tmpl.Execute(&header, data); err != nil {
+		log.Printf("Warning: failed to render --comment-author-format, using the default: %v", err)
+		header.Reset()
+		defaultCommentAuthorTemplate.Execute(&header, data)
+	}
+	return header.String() + "\n\n"
+}
+
+// postConsolidatedComment posts all source comments as a single comment on
+// the new issue, with a horizontal rule between consecutive comments, or as
+// one such comment per --comment-batch-size comments. A failure is only
+// returned with --fail-fast; the remaining parts are still posted otherwise.
+func (im *importer) postConsolidatedComment(ctx context.Context, issueNumber int, comments []Comment) error {
+	infof("Consolidating %d comments for new issue #%d", len(comments), issueNumber)
+	bodies := im.consolidatedComments(comments)
+	posted := 0
+	for _, body := range bodies {
+		if _, err := im.createComment(ctx, issueNumber, body); err != nil {
+			eventf(levelQuiet, logFields{Action: "comment_failed", IssueNumber: issueNumber, Error: err.Error()}, "Failed to create consolidated comment for issue #%d: %v\n", issueNumber, err)
+			if im.failFast {
+				return fmt.Errorf("failed to create consolidated comment for issue #%d: %v", issueNumber, err)
+			}
+			continue
+		}
+		posted++
+	}
+	if posted == len(bodies) {
+		infof("Successfully posted consolidated comments.\n")
+	}
+	return nil
+}
+
+// consolidatedComments returns the bodies of the comments that
+// postConsolidatedComment posts for comments: one, or with
+// --comment-batch-size one per batch, each headed "(part K of M)".
+func (im *importer) consolidatedComments(comments []Comment) []string {
+	if im.commentBatchSize <= 0 || len(comments) <= im.commentBatchSize {
+		return []string{im.consolidatedComment(comments, im.consolidatedHeader)}
+	}
+	batches := slices.Collect(slices.Chunk(comments, im.commentBatchSize))
+	bodies := make([]string, len(batches))
+	for i, batch := range batches {
+		header := fmt.Sprintf("(part %d of %d)", i+1, len(batches))
+		if im.consolidatedHeader != "" {
+			header = im.consolidatedHeader + " " + header
+		}
+		bodies[i] = im.consolidatedComment(batch, header)
+	}
+	return bodies
+}
+
+// consolidatedComment returns the body of a consolidated comment holding
+// comments below header, which may be empty. With --collapse-comments, the
+// comments are wrapped in collapsible blocks, one per comment or per author.
+func (im *importer) consolidatedComment(comments []Comment, header string) string {
+	var combinedComments string
+	switch im.collapseComments {
+	case "comment":
+		combinedComments = im.collapseEachComment(comments)
+	case "author":
+		combinedComments = im.collapseByAuthor(comments)
+	default:
+		combinedComments = im.joinComments(comments)
+	}
+	if header != "" {
+		combinedComments = header + "\n\n---\n\n" + combinedComments
+	}
+	return mapMentions(combinedComments, im.userMap)
+}
+
+// joinComments renders comments, each below its attribution line, with a
+// horizontal rule between consecutive comments.
+func (im *importer) joinComments(comments []Comment) string {
+	blocks := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		blocks = append(blocks, im.commentHeader(comment)+im.absolutizeURLs(strings.TrimRight(comment.Body, " \t\r\n")))
+	}
+	return strings.Join(blocks, "\n\n---\n\n")
+}
+
+// separateComment returns the body that postSeparateComments posts for a
+// single source comment.
+func (im *importer) separateComment(comment Comment) string {
+	return mapMentions(im.commentHeader(comment)+im.absolutizeURLs(comment.Body), im.userMap)
+}
+
+// postSeparateComments posts each of the comments of a source issue as its own
+// comment on the new issue, in source order, along with its reactions when
+// --migrate-reactions is set. A failed comment is logged and the rest are
+// still posted, unless --fail-fast is set; the failed ones are then recorded
+// by recordMissingComments.
+func (im *importer) postSeparateComments(ctx context.Context, issue Issue, issueNumber int, comments []Comment) error {
+	infof("Posting %d comments for new issue #%d", len(comments), issueNumber)
+	posted := 0
+	var missing []Comment
+	for i, comment := range comments {
+		commentID, err := im.createComment(ctx, issueNumber, im.separateComment(comment))
+		if err != nil {
+			eventf(levelQuiet, logFields{Action: "comment_failed", IssueNumber: issueNumber, Error: err.Error()}, "Failed to create comment %d of %d for issue #%d: %v\n", i+1, len(comments), issueNumber, err)
+			if im.failFast {
+				return fmt.Errorf("failed to create comment %d of %d for issue #%d: %v", i+1, len(comments), issueNumber, err)
+			}
+			missing = append(missing, comment)
+			continue
+		}
+		posted++
+		if im.migrateReactions {
+			if err := im.addCommentReactions(ctx, commentID, comment.Reactions); err != nil {
+				return err
+			}
+		}
+	}
+	infof("Successfully posted %d of %d comments.\n", posted, len(comments))
+	if len(missing) > 0 {
+		im.recordMissingComments(ctx, issue, issueNumber, missing)
+	}
+	return nil
+}
+
+// recordMissingComments posts a note on a new issue listing the author and
+// time of every source comment that could not be posted on it, so the gap in
+// its history is visible. The comments are also added to the failed issues
+// as an entry for issueNumber holding only them, so that --retry-from posts
+// just those instead of creating the issue again.
+func (im *importer) recordMissingComments(ctx context.Context, issue Issue, issueNumber int, missing []Comment) {
+	lines := make([]string, 0, len(missing))
+	for _, comment := range missing {
+		line := "- " + newCommentAuthorData(comment).Name
+		if comment.CreatedAt != "" {
+			line += " at " + comment.CreatedAt
+		}
+		lines = append(lines, line)
+	}
+	summary := "1 comment"
+	if len(missing) > 1 {
+		summary = fmt.Sprintf("%d comments", len(missing))
+	}
+	note := fmt.Sprintf("> **Note:** %s of the original issue failed to migrate:\n\n%s", summary, strings.Join(lines, "\n"))
+	if _, err := im.createComment(ctx, issueNumber, note); err != nil {
+		log.Printf("Failed to post the note about the missing comments on issue #%d: %v\n", issueNumber, err)
+	}
+
+	entry := issue
+	entry.Comments = missing
+	entry.ImportedAs = issueNumber
+	im.mu.Lock()
+	im.failedIssues = append(im.failedIssues, entry)
+	im.mu.Unlock()
+}
+
+// createComment posts body as a comment on the given issue. A body that is too
+// long for GitHub is split at paragraph boundaries and posted as several
+// consecutive comments. It returns the ID of the first comment posted.
+func (im *importer) createComment(ctx context.Context, issueNumber int, body string) (int64, error) {
+	parts := splitBody(body, maxBodyLength)
+	if len(parts) > 1 {
+		infof("Comment on issue #%d exceeds %d characters, splitting it into %d comments", issueNumber, maxBodyLength, len(parts))
+	}
+	var firstID int64
+	for i, part := range parts {
+		id, err := im.postComment(ctx, issueNumber, part)
+		if err != nil {
+			return firstID, err
+		}
+		if i == 0 {
+			firstID = id
+		}
+	}
+	return firstID, nil
+}
+
+// postComment posts body as a single comment on the given issue and remembers
+// it in im.postedComments. It returns the ID of the new comment.
+func (im *importer) postComment(ctx context.Context, issueNumber int, body string) (int64, error) {
+	started := time.Now()
+	var created *github.IssueComment
+	err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+		created, resp, err = im.issues.CreateComment(ctx, im.owner, im.repo, issueNumber, &github.IssueComment{Body: &body})
+		return resp, err
+	})
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if err != nil {
+		im.report.CommentsFailed++
+		return 0, err
 	}
+	im.report.CommentsPosted++
+	im.report.commentTime += time.Since(started)
+	im.postedComments[issueNumber] = append(im.postedComments[issueNumber], postedComment{id: created.GetID(), body: body})
+	return created.GetID(), nil
 }
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLog returns a buffer that receives the standard logger's output
+// until the test ends.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestCreateIssueAndCommentClosesAfterComments(t *testing.T) {
+	f := &fakeIssues{}
+	im := newTestImporter(f)
+	issues := []Issue{
+		{Number: 1, Title: "open", State: "OPEN", Comments: []Comment{{Body: "still open", Author: User{Login: "a"}}}},
+		{Number: 2, Title: "closed", State: "CLOSED", StateReason: "NOT_PLANNED", Comments: []Comment{{Body: "wontfix", Author: User{Login: "b"}}}},
+		{Number: 3, Title: "closed flag", Closed: true},
+	}
+
+	mapping, err := im.createIssueAndComment(context.Background(), issues, nil, map[int]int{})
+	if err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if want := map[int]int{1: 1, 2: 2, 3: 3}; !maps.Equal(mapping, want) {
+		t.Errorf("mapping %v, want %v", mapping, want)
+	}
+
+	if edits := f.edits[1]; len(edits) != 0 {
+		t.Errorf("open issue #1 was edited: %v", edits)
+	}
+	for _, number := range []int{2, 3} {
+		edits := f.edits[number]
+		if len(edits) != 1 || edits[0].GetState() != "closed" {
+			t.Fatalf("issue #%d edits %v, want one close", number, edits)
+		}
+	}
+	if reason := f.edits[2][0].GetStateReason(); reason != "not_planned" {
+		t.Errorf("issue #2 closed as %q, want not_planned", reason)
+	}
+
+	closed := slices.Index(f.calls, "edit issue #2 state=closed")
+	commented := slices.Index(f.calls, "comment on #2")
+	if commented < 0 || closed < commented {
+		t.Errorf("issue #2 was closed before its comment was posted: %v", f.calls)
+	}
+}
+
+func TestCreateIssueAndCommentSkipsMappedIssues(t *testing.T) {
+	f := &fakeIssues{nextNumber: 20}
+	im := newTestImporter(f)
+	issues := []Issue{{Number: 1, Title: "one"}, {Number: 2, Title: "two"}, {Number: 3, Title: "three"}}
+	previous := map[int]int{1: 10, 3: 12}
+
+	mapping, err := im.createIssueAndComment(context.Background(), issues, nil, previous)
+	if err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if len(f.created) != 1 || f.created[20].GetTitle() != "two" {
+		t.Errorf("created %v, want only old issue #2", f.created)
+	}
+	if want := map[int]int{1: 10, 2: 20, 3: 12}; !maps.Equal(mapping, want) {
+		t.Errorf("mapping %v, want %v", mapping, want)
+	}
+	if im.report.IssuesSkipped != 2 {
+		t.Errorf("skipped %d issues, want 2", im.report.IssuesSkipped)
+	}
+}
+
+func TestAssignableLogins(t *testing.T) {
+	f := &fakeIssues{assignable: map[string]bool{"alice": true, "bob": true}}
+	im := newTestImporter(f)
+	logs := captureLog(t)
+
+	users := []User{{Login: "alice"}, {Login: "mallory"}, {Login: "bob"}, {Login: "ghost"}}
+	got := im.assignableLogins(context.Background(), users)
+	if want := []string{"alice", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, login := range []string{"mallory", "ghost"} {
+		if !strings.Contains(logs.String(), "Dropping assignee @"+login) {
+			t.Errorf("dropping @%s was not logged:\n%s", login, logs)
+		}
+	}
+	if strings.Contains(logs.String(), "@alice") {
+		t.Errorf("valid assignee @alice was reported as dropped:\n%s", logs)
+	}
+}
+
+func TestIssueBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceRepo string
+		body       string
+		want       string
+	}{
+		{"empty", "", "", ""},
+		{"whitespace only", "", " \n\t\n", ""},
+		{"normal", "", "Steps to reproduce", "Steps to reproduce"},
+		{"empty with footer", "src/repo", "", "_Migrated from src/repo#4_"},
+		{"whitespace only with footer", "src/repo", "\n\n  ", "_Migrated from src/repo#4_"},
+		{"normal with footer", "src/repo", "Steps to reproduce", "Steps to reproduce\n\n_Migrated from src/repo#4_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im := newTestImporter(&fakeIssues{})
+			im.sourceRepo = tt.sourceRepo
+			issue := Issue{Number: 4, Body: tt.body}
+			if got := im.issueBody(issue, issue.Body); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateIssueLinksLeavesEmptyBodiesAlone(t *testing.T) {
+	f := &fakeIssues{}
+	im := newTestImporter(f)
+	im.sourceRepo = "src/repo"
+	issues := []Issue{{Number: 1, Body: ""}, {Number: 2, Body: "   "}, {Number: 3, Body: "no references"}}
+
+	if err := im.updateIssueLinks(context.Background(), issues, map[int]int{1: 11, 2: 12, 3: 13}); err != nil {
+		t.Fatalf("updateIssueLinks: %v", err)
+	}
+	if len(f.edits) != 0 {
+		t.Errorf("bodies without references were edited: %v", f.calls)
+	}
+}
+
+func TestUserDisplayName(t *testing.T) {
+	tests := []struct {
+		user User
+		want string
+	}{
+		{User{Login: "octocat"}, "@octocat"},
+		{User{Login: ""}, "a deleted user"},
+		{User{Login: "ghost"}, "a deleted user"},
+		{User{Login: "dependabot[bot]"}, "dependabot[bot]"},
+		{User{Login: "renovate", IsBot: true}, "renovate"},
+		{User{Login: "app/github-actions"}, "github-actions"},
+	}
+	for _, tt := range tests {
+		if got := tt.user.displayName(); got != tt.want {
+			t.Errorf("%+v.displayName() = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestAddLabelIsCreatedOnceAndAttachedToEveryIssue(t *testing.T) {
+	f := &fakeIssues{}
+	im := newTestImporter(f)
+	im.addLabels = []string{"migrated", "bug"}
+	issues := []Issue{
+		{Number: 1, Title: "one", Labels: []Label{{Name: "bug"}}},
+		{Number: 2, Title: "two"},
+		{Number: 3, Title: "three", Labels: []Label{{Name: "ui"}}},
+	}
+
+	labels, _ := findLablesAndMilestones(issues)
+	im.includeAddedLabels(labels)
+	if err := im.createLabels(context.Background(), labels); err != nil {
+		t.Fatalf("createLabels: %v", err)
+	}
+	if got, want := slices.Sorted(slices.Values(labelNames(f.createdLabels))), []string{"bug", "migrated", "ui"}; !slices.Equal(got, want) {
+		t.Errorf("created labels %v, want %v", got, want)
+	}
+
+	if _, err := im.createIssueAndComment(context.Background(), issues, nil, map[int]int{}); err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	want := map[int][]string{
+		1: {"bug", "migrated"},
+		2: {"bug", "migrated"},
+		3: {"bug", "migrated", "ui"},
+	}
+	for number, labels := range want {
+		if got := f.created[number].GetLabels(); !slices.Equal(got, labels) {
+			t.Errorf("issue #%d created with labels %v, want %v", number, got, labels)
+		}
+	}
+}
+
+func TestResumedIssueOnlyGetsItsMissingComments(t *testing.T) {
+	f := &fakeIssues{nextNumber: 60}
+	im := newTestImporter(f)
+	im.separateComments = true
+	issues := []Issue{
+		{Number: 1, Title: "one"},
+		{
+			Number:     5,
+			Title:      "five",
+			Body:       "see #1",
+			Parent:     &IssueRef{Number: 1},
+			ImportedAs: 50,
+			Comments:   []Comment{{Body: "also #1", Author: User{Login: "a"}}},
+		},
+	}
+
+	ctx := context.Background()
+	mapping, err := im.createIssueAndComment(ctx, issues, nil, map[int]int{})
+	if err != nil {
+		t.Fatalf("createIssueAndComment: %v", err)
+	}
+	if want := map[int]int{1: 60, 5: 50}; !maps.Equal(mapping, want) {
+		t.Fatalf("mapping %v, want %v", mapping, want)
+	}
+	if err := im.updateIssueLinks(ctx, issues, mapping); err != nil {
+		t.Fatalf("updateIssueLinks: %v", err)
+	}
+	if err := im.linkSubIssues(ctx, issues, mapping); err != nil {
+		t.Fatalf("linkSubIssues: %v", err)
+	}
+
+	if _, ok := f.created[50]; ok || len(f.created) != 1 {
+		t.Errorf("created %v, want only old issue #1", f.created)
+	}
+	if edits := f.edits[50]; len(edits) != 0 {
+		t.Errorf("the body of resumed issue #50 was edited: %v", edits)
+	}
+	if len(f.comments[50]) != 1 {
+		t.Fatalf("comments on #50: %q, want the missing one", f.comments[50])
+	}
+	edited, ok := f.editedComments[50001]
+	if !ok || !strings.Contains(edited, "also #60") {
+		t.Errorf("comment on #50 rewritten to %q, want it to link #60", edited)
+	}
+	if im.report.EditsFailed != 0 || im.report.SubIssuesLinked != 0 {
+		t.Errorf("%d failed edits and %d sub-issue links, want neither", im.report.EditsFailed, im.report.SubIssuesLinked)
+	}
+}
+
+func TestAssignableLoginsRetriesAndDoesNotCacheFailures(t *testing.T) {
+	f := &fakeIssues{
+		assignable:     map[string]bool{"carol": true, "dave": true},
+		assigneeErrors: map[string]int{"carol": 1, "dave": 2},
+	}
+	im := newTestImporter(f)
+	im.retryBase, im.retryMax = time.Millisecond, time.Millisecond
+	logs := captureLog(t)
+
+	users := []User{{Login: "carol"}, {Login: "dave"}}
+	if got, want := im.assignableLogins(context.Background(), users), []string{"carol"}; !slices.Equal(got, want) {
+		t.Errorf("first issue got %v, want %v", got, want)
+	}
+	if !strings.Contains(logs.String(), "could not check whether @dave is assignable") {
+		t.Errorf("failed check of @dave was not logged:\n%s", logs)
+	}
+	if _, cached := im.assignable["dave"]; cached {
+		t.Error("the failed check of @dave was cached")
+	}
+
+	if got, want := im.assignableLogins(context.Background(), users), []string{"carol", "dave"}; !slices.Equal(got, want) {
+		t.Errorf("second issue got %v, want %v", got, want)
+	}
+}
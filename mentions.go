@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// mentionRegex matches an @login mention. The @ must not directly follow a
+// word character, another @, or a dot, so e-mail addresses such as
+// alice@example.com are not treated as mentions.
+var mentionRegex = regexp.MustCompile(`(^|[^\w@.])@([A-Za-z\d](?:[A-Za-z\d-]*[A-Za-z\d])?)`)
+
+// readStringMap loads a JSON object of string keys and values, as used by the
+// user and label mapping files.
+func readStringMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return m, nil
+}
+
+// newUserMap normalizes a login mapping for case-insensitive lookups, since
+// GitHub logins are case-insensitive.
+func newUserMap(m map[string]string) map[string]string {
+	userMap := make(map[string]string, len(m))
+	for oldLogin, newLogin := range m {
+		userMap[strings.ToLower(oldLogin)] = newLogin
+	}
+	return userMap
+}
+
+// mapMentions rewrites @oldLogin mentions in text according to userMap. A
+// mapping to the empty string drops the @ so the user is named without being
+// notified. Mentions inside code and unmapped logins are left untouched.
+func mapMentions(text string, userMap map[string]string) string {
+	if len(userMap) == 0 {
+		return text
+	}
+	return rewriteOutsideCode(text, func(prose string) string {
+		return mentionRegex.ReplaceAllStringFunc(prose, func(match string) string {
+			at := strings.IndexByte(match, '@')
+			login := match[at+1:]
+			newLogin, ok := userMap[strings.ToLower(login)]
+			if !ok {
+				return match
+			}
+			if newLogin == "" {
+				return match[:at] + login
+			}
+			return match[:at] + "@" + newLogin
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestMapMentions(t *testing.T) {
+	userMap := newUserMap(map[string]string{"Alice": "alice-new", "bob": ""})
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"mapped", "Thanks @alice!", "Thanks @alice-new!"},
+		{"mapped regardless of case", "cc @ALICE", "cc @alice-new"},
+		{"unmapped", "cc @carol", "cc @carol"},
+		{"stripped", "Reported by @bob.", "Reported by bob."},
+		{"email address", "Mail alice@example.com or bob@example.com", "Mail alice@example.com or bob@example.com"},
+		{"inline code", "Use `@alice` like @alice", "Use `@alice` like @alice-new"},
+		{"fenced code", "```\n@bob\n```\n@bob", "```\n@bob\n```\nbob"},
+		{"longer login", "@alicex", "@alicex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapMentions(tt.text, userMap); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
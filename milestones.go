@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// milestoneKey returns the key under which milestones are matched, so that
+// titles differing only in case or surrounding whitespace refer to the same
+// milestone.
+func milestoneKey(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
+}
+
+// dateLayouts are the formats accepted for a milestone's due date and for
+// --since, tried in order. gh exports RFC 3339; the others are common in
+// hand-written or converted exports.
+var dateLayouts = []string{
+	time.RFC3339,
+	time.DateOnly,
+	"2006-01-02T15:04:05",
+	time.DateTime,
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// parseDate parses a date in any of dateLayouts. A date without a time zone
+// is taken to be in UTC.
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format %q", value)
+}
+
+// state returns the milestone's state in the form the REST API expects. Some
+// exports leave out the state and only say whether, or when, the milestone
+// was closed, so a closed flag or a closing date counts as closed too, and
+// anything else as open.
+func (m Milestone) state() string {
+	if strings.EqualFold(m.State, "closed") || m.Closed || (m.ClosedAt != nil && *m.ClosedAt != "") {
+		return "closed"
+	}
+	return "open"
+}
+
+// updateMilestoneState opens or closes an existing target milestone so that
+// it matches the source milestone. A failure is only returned with
+// --fail-fast.
+func (im *importer) updateMilestoneState(ctx context.Context, existing *github.Milestone, milestone Milestone) error {
+	if existing.GetState() == milestone.state() {
+		return nil
+	}
+
+	if im.dryRun {
+		infof("[dry-run] Would change state of milestone '%s' from %s to %s", milestone.Title, existing.GetState(), milestone.state())
+		return nil
+	}
+	infof("Changing state of milestone '%s' from %s to %s", milestone.Title, existing.GetState(), milestone.state())
+	err := im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.issues.EditMilestone(ctx, im.owner, im.repo, existing.GetNumber(), &github.Milestone{
+			State: github.Ptr(milestone.state()),
+		})
+		return resp, err
+	})
+	if err != nil {
+		log.Printf("Warning: failed to update milestone '%s': %v\n", milestone.Title, err)
+		im.report.EditsFailed++
+		if im.failFast {
+			return fmt.Errorf("failed to update milestone '%s': %v", milestone.Title, err)
+		}
+	}
+	return nil
+}
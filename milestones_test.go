@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v73/github"
+)
+
+func TestCreateMilestonesMapsTitlesToNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []*github.Milestone
+		issues   []Issue
+		want     map[string]int
+		created  int
+	}{
+		{
+			name:    "new milestones get the numbers GitHub assigns",
+			issues:  []Issue{{Milestone: &Milestone{Title: "v1"}}, {Milestone: &Milestone{Title: "v2"}}},
+			want:    map[string]int{"v1": 100, "v2": 101},
+			created: 2,
+		},
+		{
+			name:     "existing milestones keep their numbers",
+			existing: []*github.Milestone{{Number: github.Ptr(3), Title: github.Ptr("v1")}},
+			issues:   []Issue{{Milestone: &Milestone{Title: "v1"}}, {Milestone: &Milestone{Title: "v2"}}},
+			want:     map[string]int{"v1": 3, "v2": 100},
+			created:  1,
+		},
+		{
+			name:     "unused existing milestones are mapped too",
+			existing: []*github.Milestone{{Number: github.Ptr(7), Title: github.Ptr("old")}},
+			want:     map[string]int{"old": 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeIssues{milestonePages: [][]*github.Milestone{tt.existing}}
+			im := newTestImporter(f)
+
+			_, milestones := findLablesAndMilestones(tt.issues)
+			got, err := im.createMilestones(context.Background(), milestones)
+			if err != nil {
+				t.Fatalf("createMilestones: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("mapping %v, want %v", got, tt.want)
+			}
+			if len(f.createdMilestones) != tt.created {
+				t.Errorf("created %d milestones, want %d", len(f.createdMilestones), tt.created)
+			}
+		})
+	}
+}
+
+func TestCreateMilestonesReadsEveryPage(t *testing.T) {
+	pages := map[string]string{
+		"":  `[{"number": 1, "title": "v1"}, {"number": 2, "title": "v2"}]`,
+		"2": `[{"number": 3, "title": "v3"}]`,
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/repos/o/r/milestones" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			next := url.URL{Path: r.URL.Path, RawQuery: "page=2&per_page=100&state=all"}
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, server.URL, next.String()))
+		}
+		fmt.Fprint(w, pages[page])
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	im := newTestImporter(nil)
+	im.issues = client.Issues
+
+	got, err := im.createMilestones(context.Background(), map[string]Milestone{"v3": {Title: "v3"}})
+	if err != nil {
+		t.Fatalf("createMilestones: %v", err)
+	}
+	want := map[string]int{"v1": 1, "v2": 2, "v3": 3}
+	if !maps.Equal(got, want) {
+		t.Errorf("mapping %v, want %v", got, want)
+	}
+}
+
+func TestCreateMilestonesMatchesTitlesLoosely(t *testing.T) {
+	f := &fakeIssues{milestonePages: [][]*github.Milestone{{
+		{Number: github.Ptr(4), Title: github.Ptr("Release 1.0")},
+	}}}
+	im := newTestImporter(f)
+	issues := []Issue{
+		{Milestone: &Milestone{Title: "release 1.0"}},
+		{Milestone: &Milestone{Title: "  RELEASE 1.0 "}},
+		{Milestone: &Milestone{Title: " Beta "}},
+		{Milestone: &Milestone{Title: "beta"}},
+	}
+
+	_, milestones := findLablesAndMilestones(issues)
+	got, err := im.createMilestones(context.Background(), milestones)
+	if err != nil {
+		t.Fatalf("createMilestones: %v", err)
+	}
+	if want := map[string]int{"release 1.0": 4, "beta": 100}; !maps.Equal(got, want) {
+		t.Errorf("mapping %v, want %v", got, want)
+	}
+	if len(f.createdMilestones) != 1 || f.createdMilestones[0].GetTitle() != "Beta" {
+		t.Errorf("created %v, want only Beta with its first-seen casing", f.createdMilestones)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	noon := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	midnight := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-05-01T12:30:00Z", noon},
+		{"2024-05-01T14:30:00+02:00", noon},
+		{"2024-05-01", midnight},
+		{" 2024-05-01 ", midnight},
+		{"2024-05-01T12:30:00", noon},
+		{"2024-05-01 12:30:00", noon},
+		{"Wed, 01 May 2024 12:30:00 UTC", noon},
+		{"Wed, 01 May 2024 14:30:00 +0200", noon},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.value)
+		if err != nil {
+			t.Errorf("parseDate(%q): %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "05/01/2024", "tomorrow"} {
+		if _, err := parseDate(value); err == nil {
+			t.Errorf("parseDate(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestMilestoneState(t *testing.T) {
+	closedAt := "2024-05-01T00:00:00Z"
+	empty := ""
+	tests := []struct {
+		name      string
+		milestone Milestone
+		want      string
+	}{
+		{"open", Milestone{State: "OPEN"}, "open"},
+		{"closed", Milestone{State: "CLOSED"}, "closed"},
+		{"lowercase closed", Milestone{State: "closed"}, "closed"},
+		{"closed flag only", Milestone{Closed: true}, "closed"},
+		{"closing date only", Milestone{ClosedAt: &closedAt}, "closed"},
+		{"open with a closing date", Milestone{State: "OPEN", ClosedAt: &closedAt}, "closed"},
+		{"no state", Milestone{}, "open"},
+		{"empty closing date", Milestone{ClosedAt: &empty}, "open"},
+		{"unknown state", Milestone{State: "archived"}, "open"},
+	}
+	for _, tt := range tests {
+		if got := tt.milestone.state(); got != tt.want {
+			t.Errorf("%s: state() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// placeholderBody explains the placeholder issues created by
+// --preserve-numbers.
+const placeholderBody = "_Placeholder created during migration so that the migrated issues keep their original numbers._"
+
+// ensureEmptyTarget fails unless the target repository has no issues or pull
+// requests yet, which --preserve-numbers relies on.
+func (im *importer) ensureEmptyTarget(ctx context.Context) error {
+	existing, _, err := im.issues.ListByRepo(ctx, im.owner, im.repo, &github.IssueListByRepoOptions{
+		State:       "all",
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list existing issues: %v", err)
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%s/%s already has issues or pull requests; --preserve-numbers needs an empty repository", im.owner, im.repo)
+	}
+	return nil
+}
+
+// fillNumberGap creates and closes placeholder issues until the next issue
+// created in the target repository gets number. It is only used with a single
+// worker, so im.nextNumber needs no locking.
+func (im *importer) fillNumberGap(ctx context.Context, number int) error {
+	for ; im.nextNumber < number; im.nextNumber++ {
+		if im.dryRun {
+			infof("[dry-run] Would create and close placeholder issue #%d", im.nextNumber)
+			continue
+		}
+
+		infof("Creating placeholder issue #%d", im.nextNumber)
+		var placeholder *github.Issue
+		err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+			placeholder, resp, err = im.issues.Create(ctx, im.owner, im.repo, &github.IssueRequest{
+				Title: github.Ptr("placeholder"),
+				Body:  github.Ptr(placeholderBody),
+			})
+			return resp, err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create placeholder issue #%d: %v", im.nextNumber, err)
+		}
+		if placeholder.GetNumber() != im.nextNumber {
+			return fmt.Errorf("placeholder issue was created as #%d instead of #%d", placeholder.GetNumber(), im.nextNumber)
+		}
+
+		err = im.withRetry(ctx, func() (*github.Response, error) {
+			_, resp, err := im.issues.Edit(ctx, im.owner, im.repo, placeholder.GetNumber(), &github.IssueRequest{
+				State:       github.Ptr("closed"),
+				StateReason: github.Ptr("not_planned"),
+			})
+			return resp, err
+		})
+		if err != nil {
+			log.Printf("Failed to close placeholder issue #%d: %v\n", placeholder.GetNumber(), err)
+			im.report.EditsFailed++
+		}
+		im.report.PlaceholdersCreated++
+	}
+	return nil
+}
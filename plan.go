@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// plan is what a dry run would do, written by --plan-out so that it can be
+// reviewed or diffed by other tools.
+type plan struct {
+	LabelsToCreate     []string           `json:"labelsToCreate"`
+	MilestonesToCreate []plannedMilestone `json:"milestonesToCreate"`
+	Issues             []plannedIssue     `json:"issues"`
+}
+
+// plannedMilestone is a milestone a dry run would create, with the number it
+// was simulated to get.
+type plannedMilestone struct {
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Number int    `json:"number"`
+}
+
+// plannedIssue is an issue a dry run would create. Comments counts the
+// comments that would be posted on it, after consolidation and splitting.
+type plannedIssue struct {
+	OldNumber int      `json:"oldNumber"`
+	NewNumber int      `json:"newNumber"`
+	Title     string   `json:"title"`
+	Labels    []string `json:"labels"`
+	Milestone string   `json:"milestone,omitempty"`
+	Comments  int      `json:"comments"`
+}
+
+// write saves the plan as indented JSON at path.
+func (p *plan) write(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %v", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// writePlan saves the plan recorded during a dry run. It is a no-op when path
+// is empty.
+func (im *importer) writePlan(path string) {
+	if path == "" || im.plan == nil {
+		return
+	}
+	if err := im.plan.write(path); err != nil {
+		log.Printf("Warning: failed to write plan to %s: %v\n", path, err)
+		return
+	}
+	log.Printf("Wrote plan for %d issues to %s", len(im.plan.Issues), path)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// permissionLevels lists the repository permissions GitHub reports, from the
+// most to the least privileged.
+var permissionLevels = []string{"admin", "maintain", "push", "triage", "pull"}
+
+// preflight checks that the target repository exists and that the token can
+// write to it, so a typo or an under-scoped token is caught before anything is
+// created. During a dry run a missing write permission is only a warning.
+func (im *importer) preflight(ctx context.Context) error {
+	repository, _, err := im.client.Repositories.Get(ctx, im.owner, im.repo)
+	if err != nil {
+		var errResp *github.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("repository %s/%s does not exist or is not visible to the token", im.owner, im.repo)
+		}
+		return fmt.Errorf("failed to look up repository %s/%s: %v", im.owner, im.repo, err)
+	}
+
+	if len(repository.GetPermissions()) == 0 {
+		// GitHub App installation tokens are not given a permissions
+		// summary; a missing write permission surfaces on the first create.
+		log.Printf("Could not determine the token's permission on %s, continuing", repository.GetFullName())
+		return nil
+	}
+	level := permissionLevel(repository.GetPermissions())
+	log.Printf("Token has %q permission on %s", level, repository.GetFullName())
+	if level == "admin" || level == "maintain" || level == "push" {
+		return nil
+	}
+	if im.dryRun {
+		log.Printf("Warning: the token cannot write to %s/%s, a real run would fail", im.owner, im.repo)
+		return nil
+	}
+	return fmt.Errorf("the token has %q permission on %s/%s, but write access is required", level, im.owner, im.repo)
+}
+
+// ensureRepository creates the target repository, with the given visibility
+// and description, unless it already exists. The owner may be the
+// authenticated user or an organization. It reports whether the repository
+// exists afterwards, which during a dry run is only the case if it already
+// did.
+func (im *importer) ensureRepository(ctx context.Context, private bool, description string) (bool, error) {
+	_, _, err := im.client.Repositories.Get(ctx, im.owner, im.repo)
+	if err == nil {
+		log.Printf("Repository %s already exists, not creating it.", im.targetRepo())
+		return true, nil
+	}
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response.StatusCode != http.StatusNotFound {
+		return false, fmt.Errorf("failed to look up repository %s: %v", im.targetRepo(), err)
+	}
+
+	visibility := "public"
+	if private {
+		visibility = "private"
+	}
+	if im.dryRun {
+		log.Printf("[dry-run] Would create %s repository %s", visibility, im.targetRepo())
+		return false, nil
+	}
+
+	// Repositories.Create takes an empty organization for a repository of
+	// the authenticated user, and cannot create one for any other user.
+	ownerType, err := im.resolveOwnerType(ctx)
+	if err != nil {
+		return false, err
+	}
+	org := im.owner
+	if ownerType == "user" {
+		user, _, err := im.client.Users.Get(ctx, "")
+		if err != nil {
+			return false, fmt.Errorf("failed to look up the authenticated user: %v", err)
+		}
+		if !strings.EqualFold(user.GetLogin(), im.owner) {
+			return false, fmt.Errorf("%s is a user other than the authenticated user %s, repositories can only be created for yourself or an organization", im.owner, user.GetLogin())
+		}
+		org = ""
+	}
+
+	log.Printf("Creating %s repository %s", visibility, im.targetRepo())
+	err = im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.client.Repositories.Create(ctx, org, &github.Repository{
+			Name:        github.Ptr(im.repo),
+			Private:     github.Ptr(private),
+			Description: github.Ptr(description),
+		})
+		return resp, err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create repository %s: %v", im.targetRepo(), err)
+	}
+	return true, nil
+}
+
+// resolveOwnerType returns whether the owner of the target repository is a
+// "user" or an "org". Unless --owner-type says which, it is looked up once
+// and remembered.
+func (im *importer) resolveOwnerType(ctx context.Context) (string, error) {
+	if im.ownerType == "user" || im.ownerType == "org" {
+		return im.ownerType, nil
+	}
+	_, _, err := im.client.Organizations.Get(ctx, im.owner)
+	if err == nil {
+		im.ownerType = "org"
+	} else {
+		var errResp *github.ErrorResponse
+		if !errors.As(err, &errResp) || errResp.Response.StatusCode != http.StatusNotFound {
+			return "", fmt.Errorf("failed to look up whether %s is an organization: %v", im.owner, err)
+		}
+		if _, _, err := im.client.Users.Get(ctx, im.owner); err != nil {
+			return "", fmt.Errorf("%s is neither an organization nor a user visible to the token: %v", im.owner, err)
+		}
+		im.ownerType = "user"
+	}
+	debugf("Detected that owner %s is a %s (--owner-type auto)", im.owner, im.ownerType)
+	return im.ownerType, nil
+}
+
+// permissionLevel returns the highest permission granted in permissions, or
+// "none" when nothing is granted.
+func permissionLevel(permissions map[string]bool) string {
+	for _, level := range permissionLevels {
+		if permissions[level] {
+			return level
+		}
+	}
+	return "none"
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// progress numbers the issues created in Phase 3 as "[N/M]" and estimates how
+// long the remaining ones will take. It is safe to use from several workers.
+type progress struct {
+	mu sync.Mutex
+	// total is the number of source issues left after filtering, including
+	// those skipped because they were already imported.
+	total int
+	// started counts the issues begun so far, starting from the skipped
+	// ones so that the counter matches the position in the input.
+	started int
+	// pending and finished count the issues to be created in this run and
+	// those done so far, from which the time left is estimated.
+	pending  int
+	finished int
+	start    time.Time
+}
+
+// newProgress returns a progress for total issues, of which skipped need no
+// work in this run.
+func newProgress(total, skipped int) *progress {
+	return &progress{total: total, started: skipped, pending: total - skipped, start: time.Now()}
+}
+
+// next counts another issue as started and returns its "[N/M]" prefix.
+func (p *progress) next() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started++
+	return fmt.Sprintf("[%d/%d]", p.started, p.total)
+}
+
+// done counts another issue as finished.
+func (p *progress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finished++
+}
+
+// eta returns a note on the estimated time left, based on the average time
+// per finished issue, or "" until the first issue has finished.
+func (p *progress) eta() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished == 0 || p.finished >= p.pending {
+		return ""
+	}
+	perIssue := time.Since(p.start) / time.Duration(p.finished)
+	left := perIssue * time.Duration(p.pending-p.finished)
+	return fmt.Sprintf(" (about %s left)", left.Round(time.Second))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// addToProject adds a newly created issue to the Projects v2 board given by
+// --project-id. A failure is listed in the report and only returned with
+// --fail-fast.
+func (im *importer) addToProject(ctx context.Context, issue *github.Issue) error {
+	const mutation = `mutation($projectId: ID!, $contentId: ID!) {
+  addProjectV2ItemById(input: {projectId: $projectId, contentId: $contentId}) {
+    item { id }
+  }
+}`
+	infof("Adding issue #%d to project %s", issue.GetNumber(), im.projectID)
+	err := im.graphQL(ctx, mutation, map[string]any{"projectId": im.projectID, "contentId": issue.GetNodeID()}, nil)
+	if err != nil {
+		eventf(levelQuiet, logFields{Action: "project_item_failed", IssueNumber: issue.GetNumber(), Error: err.Error()}, "Failed to add issue #%d to the project: %v\n", issue.GetNumber(), err)
+		im.mu.Lock()
+		im.report.ProjectItemsFailed = append(im.report.ProjectItemsFailed, issue.GetNumber())
+		im.mu.Unlock()
+		if im.failFast {
+			return fmt.Errorf("failed to add issue #%d to the project: %v", issue.GetNumber(), err)
+		}
+		return nil
+	}
+
+	im.mu.Lock()
+	im.report.ProjectItemsAdded++
+	im.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// ReactionGroup is one entry of the reactionGroups exported by gh: a reaction
+// content, such as "THUMBS_UP", and how many users reacted with it.
+type ReactionGroup struct {
+	Content string `json:"content"`
+	Users   struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"users"`
+}
+
+// reactionContents maps the reaction contents exported by gh onto the values
+// accepted by the REST API.
+var reactionContents = map[string]string{
+	"THUMBS_UP":   "+1",
+	"THUMBS_DOWN": "-1",
+	"LAUGH":       "laugh",
+	"CONFUSED":    "confused",
+	"HEART":       "heart",
+	"HOORAY":      "hooray",
+	"ROCKET":      "rocket",
+	"EYES":        "eyes",
+}
+
+// reactionsToAdd returns the REST contents of the reactions in groups that at
+// least one user reacted with. The token can only react once per content, so
+// each content is returned once however many users used it.
+func reactionsToAdd(groups []ReactionGroup) []string {
+	var contents []string
+	for _, group := range groups {
+		content, ok := reactionContents[group.Content]
+		if !ok || group.Users.TotalCount == 0 {
+			continue
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+// addIssueReactions replays the source reactions on a new issue.
+func (im *importer) addIssueReactions(ctx context.Context, issueNumber int, groups []ReactionGroup) error {
+	return im.addReactions(ctx, fmt.Sprintf("issue #%d", issueNumber), groups, func(content string) (*github.Response, error) {
+		_, resp, err := im.client.Reactions.CreateIssueReaction(ctx, im.owner, im.repo, issueNumber, content)
+		return resp, err
+	})
+}
+
+// addCommentReactions replays the source reactions on a new comment.
+func (im *importer) addCommentReactions(ctx context.Context, commentID int64, groups []ReactionGroup) error {
+	return im.addReactions(ctx, fmt.Sprintf("comment %d", commentID), groups, func(content string) (*github.Response, error) {
+		_, resp, err := im.client.Reactions.CreateIssueCommentReaction(ctx, im.owner, im.repo, commentID, content)
+		return resp, err
+	})
+}
+
+// addReactions adds one reaction per content in groups through add. A failure
+// is only returned with --fail-fast.
+func (im *importer) addReactions(ctx context.Context, target string, groups []ReactionGroup, add func(content string) (*github.Response, error)) error {
+	for _, content := range reactionsToAdd(groups) {
+		err := im.withRetry(ctx, func() (*github.Response, error) {
+			return add(content)
+		})
+		if err != nil {
+			log.Printf("Failed to add %s reaction to %s: %v\n", content, target, err)
+			im.mu.Lock()
+			im.report.EditsFailed++
+			im.mu.Unlock()
+			if im.failFast {
+				return fmt.Errorf("failed to add %s reaction to %s: %v", content, target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// exportedReactions converts the reaction counts returned by the REST API into
+// the reactionGroups format exported by gh.
+func exportedReactions(reactions *github.Reactions) []ReactionGroup {
+	if reactions == nil {
+		return nil
+	}
+	counts := []struct {
+		content string
+		count   int
+	}{
+		{"THUMBS_UP", reactions.GetPlusOne()},
+		{"THUMBS_DOWN", reactions.GetMinusOne()},
+		{"LAUGH", reactions.GetLaugh()},
+		{"CONFUSED", reactions.GetConfused()},
+		{"HEART", reactions.GetHeart()},
+		{"HOORAY", reactions.GetHooray()},
+		{"ROCKET", reactions.GetRocket()},
+		{"EYES", reactions.GetEyes()},
+	}
+
+	var groups []ReactionGroup
+	for _, c := range counts {
+		if c.count == 0 {
+			continue
+		}
+		group := ReactionGroup{Content: c.content}
+		group.Users.TotalCount = c.count
+		groups = append(groups, group)
+	}
+	return groups
+}
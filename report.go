@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// report collects what happened during a run so that it can be summarized at
+// the end, and optionally written out as JSON. EditsFailed counts failed edits
+// of items that were already created, such as closing or pinning an issue or
+// rewriting its links; the failed link rewrites are also listed individually
+// in LinkUpdatesFailed.
+type report struct {
+	LabelsCreated     []string       `json:"labelsCreated"`
+	LabelsSkipped     []string       `json:"labelsSkipped"`
+	LabelsFailed      []string       `json:"labelsFailed"`
+	MilestonesCreated []string       `json:"milestonesCreated"`
+	MilestonesFailed  []string       `json:"milestonesFailed"`
+	IssuesCreated     int            `json:"issuesCreated"`
+	IssuesSkipped     int            `json:"issuesSkipped"`
+	IssuesFailed      []issueFailure `json:"issuesFailed"`
+	CommentsPosted    int            `json:"commentsPosted"`
+	CommentsFailed    int            `json:"commentsFailed"`
+	LinksRewritten    int            `json:"linksRewritten"`
+	EditsFailed       int            `json:"editsFailed"`
+	LinkUpdatesFailed []linkFailure  `json:"linkUpdatesFailed"`
+	SubIssuesLinked   int            `json:"subIssuesLinked"`
+
+	ProjectItemsAdded  int   `json:"projectItemsAdded"`
+	ProjectItemsFailed []int `json:"projectItemsFailed"`
+
+	DanglingLinks []danglingLink `json:"danglingLinks"`
+
+	PlaceholdersCreated int `json:"placeholdersCreated"`
+
+	AttachmentsCopied int      `json:"attachmentsCopied"`
+	AttachmentsFailed []string `json:"attachmentsFailed"`
+
+	RateLimitRetries int `json:"rateLimitRetries"`
+	TransientRetries int `json:"transientRetries"`
+
+	PhaseDurations    []phaseDuration `json:"phaseDurations"`
+	SecondsPerIssue   float64         `json:"secondsPerIssue"`
+	SecondsPerComment float64         `json:"secondsPerComment"`
+
+	// issueTime and commentTime add up the time spent importing each issue,
+	// including its comments, and posting each comment.
+	issueTime   time.Duration
+	commentTime time.Duration
+}
+
+// issueFailure identifies a source issue that could not be created.
+type issueFailure struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Error  string `json:"error"`
+}
+
+// linkFailure identifies a new issue body or comment whose links could not be
+// rewritten in Phase 4. CommentID is 0 for the issue body.
+type linkFailure struct {
+	Number    int    `json:"number"`
+	OldNumber int    `json:"oldNumber"`
+	CommentID int64  `json:"commentId,omitempty"`
+	Error     string `json:"error"`
+}
+
+// danglingLink lists the #N references in a source issue and its comments
+// that could not be remapped because no issue was created for them. Missing
+// numbers are at most the highest known source issue number, so they most
+// likely refer to issues that were not migrated or to pull requests; Unknown
+// numbers are higher than that.
+type danglingLink struct {
+	Number    int   `json:"number"`
+	OldNumber int   `json:"oldNumber"`
+	Missing   []int `json:"missing,omitempty"`
+	Unknown   []int `json:"unknown,omitempty"`
+}
+
+// finishTimings records the phase durations and the average time spent per
+// created issue and per posted comment.
+func (r *report) finishTimings(phases []phaseDuration) {
+	r.PhaseDurations = phases
+	if r.IssuesCreated > 0 {
+		r.SecondsPerIssue = r.issueTime.Seconds() / float64(r.IssuesCreated)
+	}
+	if r.CommentsPosted > 0 {
+		r.SecondsPerComment = r.commentTime.Seconds() / float64(r.CommentsPosted)
+	}
+}
+
+// print logs a human-readable summary of the report.
+func (r *report) print() {
+	log.Println("--- Summary ---")
+	log.Printf("Labels:     %d created, %d already existed, %d failed", len(r.LabelsCreated), len(r.LabelsSkipped), len(r.LabelsFailed))
+	log.Printf("Milestones: %d created, %d failed", len(r.MilestonesCreated), len(r.MilestonesFailed))
+	log.Printf("Issues:     %d created, %d skipped, %d failed", r.IssuesCreated, r.IssuesSkipped, len(r.IssuesFailed))
+	if r.PlaceholdersCreated > 0 {
+		log.Printf("Placeholders: %d created", r.PlaceholdersCreated)
+	}
+	log.Printf("Comments:   %d posted, %d failed", r.CommentsPosted, r.CommentsFailed)
+	log.Printf("Links:      %d rewritten, %d issues with dangling references", r.LinksRewritten, len(r.DanglingLinks))
+	log.Printf("Sub-issues: %d linked", r.SubIssuesLinked)
+	log.Printf("Project:    %d added, %d failed", r.ProjectItemsAdded, len(r.ProjectItemsFailed))
+	log.Printf("Edits:      %d failed", r.EditsFailed)
+	log.Printf("Retries:    %d after rate limits, %d after transient errors", r.RateLimitRetries, r.TransientRetries)
+	if len(r.PhaseDurations) > 0 {
+		phases := make([]string, len(r.PhaseDurations))
+		for i, p := range r.PhaseDurations {
+			phases[i] = fmt.Sprintf("phase %d %s", p.Phase, time.Duration(p.Seconds*float64(time.Second)).Round(time.Millisecond))
+		}
+		log.Printf("Timing:     %s; %.2fs per issue, %.2fs per comment", strings.Join(phases, ", "), r.SecondsPerIssue, r.SecondsPerComment)
+	}
+	if r.AttachmentsCopied > 0 || len(r.AttachmentsFailed) > 0 {
+		log.Printf("Attachments: %d copied, %d failed", r.AttachmentsCopied, len(r.AttachmentsFailed))
+	}
+
+	if len(r.LabelsFailed) > 0 {
+		log.Printf("Failed labels: %s", strings.Join(r.LabelsFailed, ", "))
+	}
+	if len(r.MilestonesFailed) > 0 {
+		log.Printf("Failed milestones: %s", strings.Join(r.MilestonesFailed, ", "))
+	}
+	for _, f := range r.IssuesFailed {
+		log.Printf("Failed issue #%d \"%s\": %s", f.Number, f.Title, f.Error)
+	}
+	for _, source := range r.AttachmentsFailed {
+		log.Printf("Failed to copy attachment %s", source)
+	}
+	for _, n := range r.ProjectItemsFailed {
+		log.Printf("Failed to add issue #%d to the project", n)
+	}
+	for _, d := range r.DanglingLinks {
+		if len(d.Missing) > 0 {
+			log.Printf("Issue #%d (from old #%d) references issues that were not migrated: %s", d.Number, d.OldNumber, issueRefs(d.Missing))
+		}
+		if len(d.Unknown) > 0 {
+			log.Printf("Issue #%d (from old #%d) references numbers beyond the highest source issue: %s", d.Number, d.OldNumber, issueRefs(d.Unknown))
+		}
+	}
+	for _, f := range r.LinkUpdatesFailed {
+		if f.CommentID != 0 {
+			log.Printf("Failed to rewrite links in comment %d on issue #%d (from old #%d): %s", f.CommentID, f.Number, f.OldNumber, f.Error)
+		} else {
+			log.Printf("Failed to rewrite links in the body of issue #%d (from old #%d): %s", f.Number, f.OldNumber, f.Error)
+		}
+	}
+}
+
+// issueRefs formats issue numbers as a comma-separated list of #N references.
+func issueRefs(numbers []int) string {
+	refs := make([]string, len(numbers))
+	for i, n := range numbers {
+		refs[i] = fmt.Sprintf("#%d", n)
+	}
+	return strings.Join(refs, ", ")
+}
+
+// hasFailures reports whether any create, edit, or comment operation failed.
+func (r *report) hasFailures() bool {
+	return len(r.LabelsFailed) > 0 || len(r.MilestonesFailed) > 0 || len(r.IssuesFailed) > 0 ||
+		r.CommentsFailed > 0 || r.EditsFailed > 0 || len(r.ProjectItemsFailed) > 0 ||
+		len(r.AttachmentsFailed) > 0
+}
+
+// write saves the report as indented JSON at path.
+func (r *report) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// defaultSecondaryRateLimitWait is used when GitHub reports a secondary rate
+// limit without telling us how long to back off.
+const defaultSecondaryRateLimitWait = time.Minute
+
+// throttle spaces out mutating API calls so a large migration does not burst
+// into GitHub's secondary rate limits. A nil throttle does not limit anything.
+type throttle struct {
+	ticker *time.Ticker
+}
+
+// newThrottle returns a throttle allowing rps calls per second, or nil when
+// rps is not positive.
+func newThrottle(rps float64) *throttle {
+	if rps <= 0 {
+		return nil
+	}
+	return &throttle{ticker: time.NewTicker(time.Duration(float64(time.Second) / rps))}
+}
+
+// wait blocks until the next call is allowed to fire or ctx is done.
+func (t *throttle) wait(ctx context.Context) error {
+	if t == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-t.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Default bounds of the exponential backoff between retries of a call that
+// failed with a transient error.
+const (
+	defaultRetryBase = time.Second
+	defaultRetryMax  = 30 * time.Second
+)
+
+// withRetry runs a mutating API call and retries it when GitHub reports that
+// the primary or secondary rate limit was exceeded, sleeping until the limit
+// resets or for the advertised Retry-After duration. Calls that fail with a
+// 5xx status or a network error are retried as well, after an exponential
+// backoff from im.retryBase up to im.retryMax; other 4xx errors are returned
+// right away. At most im.maxRetries retries are attempted before the last
+// error is returned. Every attempt first passes through the importer's
+// throttle, and waiting stops as soon as ctx is done.
+func (im *importer) withRetry(ctx context.Context, call func() (*github.Response, error)) error {
+	for attempt := 1; ; attempt++ {
+		if err := im.throttle.wait(ctx); err != nil {
+			return err
+		}
+		_, err := call()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || attempt > im.maxRetries {
+			return err
+		}
+
+		if wait, ok := rateLimitWait(err); ok {
+			log.Printf("Rate limit exceeded, waiting %s before retrying (attempt %d of %d)", wait.Round(time.Second), attempt, im.maxRetries)
+			im.countRetry(&im.report.RateLimitRetries)
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isTransient(err) {
+			return err
+		}
+		wait := im.backoff(attempt)
+		log.Printf("Transient error, waiting %s before retrying (attempt %d of %d): %v", wait, attempt, im.maxRetries, err)
+		im.countRetry(&im.report.TransientRetries)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// countRetry increments one of the report's retry counters.
+func (im *importer) countRetry(counter *int) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	*counter++
+}
+
+// sleep waits for d, returning early with ctx's error when ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns how long to wait before the given retry of a call that
+// failed with a transient error: im.retryBase, doubled for every earlier
+// attempt, and at most im.retryMax.
+func (im *importer) backoff(attempt int) time.Duration {
+	base, limit := im.retryBase, im.retryMax
+	if base <= 0 {
+		base = defaultRetryBase
+	}
+	if limit <= 0 {
+		limit = defaultRetryMax
+	}
+	wait := base
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
+
+// isTransient reports whether err is worth retrying after a backoff: a 5xx
+// response from GitHub, or a network error such as a timeout or a reset
+// connection.
+func isTransient(err error) bool {
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.Response != nil && errResp.Response.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// rateLimitWait reports how long to wait before retrying a call that failed
+// with err, and whether err is a rate limit error at all.
+func rateLimitWait(err error) (time.Duration, bool) {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return max(time.Until(rateLimitErr.Rate.Reset.Time), 0) + time.Second, true
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return defaultSecondaryRateLimitWait, true
+	}
+
+	return 0, false
+}
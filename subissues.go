@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// IssueRef refers to another source issue by number.
+type IssueRef struct {
+	Number int `json:"number"`
+}
+
+// linkSubIssues makes every new issue whose source issue had a parent a
+// sub-issue of the new parent issue. It runs after all issues exist, so both
+// ends of every relationship can be resolved through oldToNewIssueNumbers. It
+// stops when ctx is done, or with --fail-fast at the first failed link.
+func (im *importer) linkSubIssues(ctx context.Context, issues []Issue, oldToNewIssueNumbers map[int]int) error {
+	for _, sourceIssue := range issues {
+		// A resumed entry was linked to its parent by the run that
+		// created it, and an issue that was not created for its source
+		// issue is not linked on its behalf.
+		if sourceIssue.Parent == nil || sourceIssue.ImportedAs != 0 || im.linkTargetsOnly[sourceIssue.Number] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		childNumber, childOK := oldToNewIssueNumbers[sourceIssue.Number]
+		parentNumber, parentOK := oldToNewIssueNumbers[sourceIssue.Parent.Number]
+		if !childOK || !parentOK {
+			infof("Skipping sub-issue link from old #%d to its parent old #%d, as one of them was not created.", sourceIssue.Number, sourceIssue.Parent.Number)
+			continue
+		}
+
+		if im.dryRun {
+			infof("[dry-run] Would make issue #%d a sub-issue of #%d", childNumber, parentNumber)
+			continue
+		}
+
+		infof("Making issue #%d a sub-issue of #%d...", childNumber, parentNumber)
+		if err := im.addSubIssue(ctx, parentNumber, childNumber); err != nil {
+			log.Printf("Failed to make issue #%d a sub-issue of #%d: %v\n", childNumber, parentNumber, err)
+			im.report.EditsFailed++
+			if im.failFast {
+				return fmt.Errorf("failed to make issue #%d a sub-issue of #%d: %v", childNumber, parentNumber, err)
+			}
+			continue
+		}
+		im.report.SubIssuesLinked++
+	}
+	return nil
+}
+
+// addSubIssue adds the new issue childNumber as a sub-issue of parentNumber.
+// The API identifies the sub-issue by its ID rather than its number, so the
+// child is looked up first.
+func (im *importer) addSubIssue(ctx context.Context, parentNumber, childNumber int) error {
+	var child *github.Issue
+	err := im.withRetry(ctx, func() (resp *github.Response, err error) {
+		child, resp, err = im.issues.Get(ctx, im.owner, im.repo, childNumber)
+		return resp, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up issue #%d: %v", childNumber, err)
+	}
+	return im.withRetry(ctx, func() (*github.Response, error) {
+		_, resp, err := im.client.SubIssue.Add(ctx, im.owner, im.repo, int64(parentNumber), github.SubIssueRequest{SubIssueID: child.GetID()})
+		return resp, err
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// resolveTeam looks up the --assign-team slug in the organization owning the
+// target repository, once before any issue is created, so a typo stops the
+// run early. GitHub issues cannot be assigned to teams, so the team is
+// @mentioned on every new issue instead, which notifies its members.
+func (im *importer) resolveTeam(ctx context.Context, slug string) error {
+	ownerType, err := im.resolveOwnerType(ctx)
+	if err != nil {
+		return err
+	}
+	if ownerType != "org" {
+		return fmt.Errorf("%s is not an organization, so it has no teams", im.owner)
+	}
+	team, _, err := im.client.Teams.GetTeamBySlug(ctx, im.owner, slug)
+	if err != nil {
+		return fmt.Errorf("failed to look up team %s in %s: %v", slug, im.owner, err)
+	}
+	im.teamMention = fmt.Sprintf("@%s/%s", im.owner, team.GetSlug())
+	log.Printf("Routing new issues to team %s (%s)", team.GetName(), im.teamMention)
+	return nil
+}
+
+// mentionTeam posts a comment @mentioning the --assign-team team on a new
+// issue. A failure is only returned with --fail-fast.
+func (im *importer) mentionTeam(ctx context.Context, issueNumber int) error {
+	infof("Mentioning team %s on issue #%d", im.teamMention, issueNumber)
+	if _, err := im.createComment(ctx, issueNumber, fmt.Sprintf("This issue is assigned to %s.", im.teamMention)); err != nil {
+		eventf(levelQuiet, logFields{Action: "comment_failed", IssueNumber: issueNumber, Error: err.Error()}, "Failed to mention team %s on issue #%d: %v\n", im.teamMention, issueNumber, err)
+		if im.failFast {
+			return fmt.Errorf("failed to mention team %s on issue #%d: %v", im.teamMention, issueNumber, err)
+		}
+	}
+	return nil
+}
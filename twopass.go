@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// completeTwoPass is the second pass of --two-pass. Every issue created in the
+// first pass has a placeholder body; now that all new numbers are known, each
+// gets its full body, with the issue links already rewritten, in a single
+// edit, followed by its comments, also rewritten, and everything else
+// completeIssue adds. It stops when ctx is done, or with --fail-fast at the
+// first failure.
+func (im *importer) completeTwoPass(ctx context.Context, issues []Issue, oldToNewIssueNumbers map[int]int) error {
+	infof("All issues created, writing bodies and comments with the final issue numbers")
+	return im.forEachIssue(ctx, issues, func(sourceIssue Issue) error {
+		im.mu.Lock()
+		createdIssue, ok := im.createdIssues[sourceIssue.Number]
+		im.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		newlyCreatedNumber := createdIssue.GetNumber()
+
+		issue, rewrites := im.withRewrittenLinks(sourceIssue, oldToNewIssueNumbers)
+		head, overflow := im.splitSourceBody(issue)
+		body := im.issueBody(issue, head)
+		if overflow != "" {
+			overflow = mapMentions(im.absolutizeURLs(overflow), im.userMap)
+		}
+
+		if body != createdIssue.GetBody() {
+			infof("Writing body of new issue #%d (from old #%d)...", newlyCreatedNumber, issue.Number)
+			err := im.withRetry(ctx, func() (*github.Response, error) {
+				_, resp, err := im.issues.Edit(ctx, im.owner, im.repo, newlyCreatedNumber, &github.IssueRequest{Body: &body})
+				return resp, err
+			})
+			if err != nil {
+				eventf(levelQuiet, logFields{Action: "link_update_failed", IssueNumber: newlyCreatedNumber, OldNumber: issue.Number, Error: err.Error()}, "Failed to write body of new issue #%d: %v\n", newlyCreatedNumber, err)
+				im.mu.Lock()
+				im.report.EditsFailed++
+				im.report.LinkUpdatesFailed = append(im.report.LinkUpdatesFailed, linkFailure{Number: newlyCreatedNumber, OldNumber: issue.Number, Error: err.Error()})
+				im.mu.Unlock()
+				if im.failFast {
+					return fmt.Errorf("failed to write body of new issue #%d: %v", newlyCreatedNumber, err)
+				}
+			}
+		}
+		im.mu.Lock()
+		im.report.LinksRewritten += rewrites
+		im.mu.Unlock()
+
+		return im.completeIssue(ctx, issue, createdIssue, overflow)
+	})
+}
+
+// withRewrittenLinks returns a copy of issue whose body and comments have
+// their issue links rewritten to the new numbers, along with the number of
+// links rewritten. The source issue is left untouched, so the dangling links
+// can still be found in it.
+func (im *importer) withRewrittenLinks(issue Issue, oldToNewIssueNumbers map[int]int) (Issue, int) {
+	body, rewrites, _ := rewriteIssueLinks(issue.Body, oldToNewIssueNumbers, im.sourceRepo, im.targetRepo())
+	issue.Body = body
+	count := len(rewrites)
+
+	comments := make([]Comment, len(issue.Comments))
+	for i, comment := range issue.Comments {
+		comment.Body, rewrites, _ = rewriteIssueLinks(comment.Body, oldToNewIssueNumbers, im.sourceRepo, im.targetRepo())
+		count += len(rewrites)
+		comments[i] = comment
+	}
+	issue.Comments = comments
+	return issue, count
+}
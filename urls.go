@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// markdownLinkRegex matches the target of a Markdown link or image, such as
+// [text](docs/setup.md) or ![alt](img/screenshot.png). The first group is
+// "!" for images.
+var markdownLinkRegex = regexp.MustCompile(`(!?)\[[^\]\n]*\]\(\s*<?([^)\s>]+)`)
+
+// htmlImageRegex matches the src of an HTML <img> tag.
+var htmlImageRegex = regexp.MustCompile(`(?i)<img\b[^>]*?\bsrc\s*=\s*["']([^"']+)["']`)
+
+// urlSchemeRegex matches the scheme of an absolute URL, such as https: or
+// mailto:.
+var urlSchemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// webURL returns the web address of the GitHub instance behind baseURL, or
+// of github.com when baseURL is empty, without a trailing slash.
+func webURL(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if baseURL == "" || err != nil || u.Host == "" {
+		return "https://github.com"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// absolutizeURLs rewrites the relative link and image targets in text, which
+// break once the text is moved to another repository, into absolute URLs in
+// the source repository. Absolute URLs, anchors, and anything inside code
+// blocks or inline code are left alone. Without --source-repo, text is
+// returned unchanged.
+func (im *importer) absolutizeURLs(text string) string {
+	if im.sourceRepo == "" {
+		return text
+	}
+	return rewriteOutsideCode(text, func(prose string) string {
+		prose = replaceSubmatch(prose, markdownLinkRegex, 2, func(match []string) string {
+			return im.absoluteURL(match[2], match[1] == "!")
+		})
+		return replaceSubmatch(prose, htmlImageRegex, 1, func(match []string) string {
+			return im.absoluteURL(match[1], true)
+		})
+	})
+}
+
+// absoluteURL resolves a link target found in the source repository. Paths
+// starting with / are relative to the GitHub instance; other relative paths
+// point into the source repository's default branch, at the raw file for
+// images so that they render, and at the file page otherwise.
+func (im *importer) absoluteURL(ref string, image bool) string {
+	switch {
+	case urlSchemeRegex.MatchString(ref), strings.HasPrefix(ref, "//"), strings.HasPrefix(ref, "#"):
+		return ref
+	case strings.HasPrefix(ref, "/"):
+		return im.webURL + ref
+	}
+	kind := "blob"
+	if image {
+		kind = "raw"
+	}
+	return fmt.Sprintf("%s/%s/%s/HEAD/%s", im.webURL, im.sourceRepo, kind, strings.TrimPrefix(ref, "./"))
+}
+
+// replaceSubmatch replaces group n of every match of re in text with the
+// result of replace, which receives the match and its groups.
+func replaceSubmatch(text string, re *regexp.Regexp, n int, replace func(match []string) string) string {
+	var updated strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		match := make([]string, len(m)/2)
+		for i := range match {
+			if m[2*i] >= 0 {
+				match[i] = text[m[2*i]:m[2*i+1]]
+			}
+		}
+		updated.WriteString(text[last:m[2*n]])
+		updated.WriteString(replace(match))
+		last = m[2*n+1]
+	}
+	updated.WriteString(text[last:])
+	return updated.String()
+}
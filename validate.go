@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// issueProblems returns a description of everything in issue that would make
+// it fail or be imported incorrectly, or nil when it looks fine.
+func issueProblems(issue Issue) []string {
+	var problems []string
+	if strings.TrimSpace(issue.Title) == "" {
+		problems = append(problems, "title is empty")
+	}
+	for i, label := range issue.Labels {
+		if strings.TrimSpace(label.Name) == "" {
+			problems = append(problems, fmt.Sprintf("label %d has no name", i+1))
+		}
+	}
+	if m := issue.Milestone; m != nil {
+		if strings.TrimSpace(m.Title) == "" {
+			problems = append(problems, "milestone has no title")
+		}
+		if m.DueOn != nil && strings.TrimSpace(*m.DueOn) != "" {
+			if _, err := parseDate(*m.DueOn); err != nil {
+				problems = append(problems, fmt.Sprintf("milestone %q has an invalid due date %q", m.Title, *m.DueOn))
+			}
+		}
+	}
+	return problems
+}
+
+// validateIssues splits issues into those without problems and a report of
+// the problems found in the others, one line per issue.
+func validateIssues(issues []Issue) (valid []Issue, problems []string) {
+	for _, issue := range issues {
+		if issueProblems := issueProblems(issue); len(issueProblems) > 0 {
+			problems = append(problems, fmt.Sprintf("issue #%d: %s", issue.Number, strings.Join(issueProblems, "; ")))
+			continue
+		}
+		valid = append(valid, issue)
+	}
+	return valid, problems
+}
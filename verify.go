@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v73/github"
+)
+
+// verifyReport is the outcome of --verify: which source issues are missing
+// from the mapping, and which target issues differ from what the import
+// should have produced.
+type verifyReport struct {
+	Checked     int              `json:"checked"`
+	Matched     int              `json:"matched"`
+	NotImported []int            `json:"notImported"`
+	Mismatches  []verifyMismatch `json:"mismatches"`
+	LabelDrift  []labelDrift     `json:"labelDrift"`
+}
+
+// verifyMismatch lists the differences found on one target issue.
+type verifyMismatch struct {
+	Number    int      `json:"number"`
+	OldNumber int      `json:"oldNumber"`
+	Problems  []string `json:"problems"`
+}
+
+// labelDrift lists how a label of the target repository differs from the
+// source label of the same name.
+type labelDrift struct {
+	Name     string   `json:"name"`
+	Problems []string `json:"problems"`
+}
+
+// passed reports whether every source issue was found in the target
+// repository as expected. Label drift is only a warning and does not count.
+func (r *verifyReport) passed() bool {
+	return len(r.NotImported) == 0 && len(r.Mismatches) == 0
+}
+
+// write saves the verification report as indented JSON at path.
+func (r *verifyReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode verification report: %v", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// verify compares every issue created by an earlier run, as recorded in
+// oldToNewIssueNumbers, against its source issue: the title, the set of
+// labels, the milestone, and the number of comments must match what the
+// import would produce with the same options. It only reads from the target
+// repository. Source issues missing from the mapping are reported as not
+// imported.
+func (im *importer) verify(ctx context.Context, issues []Issue, oldToNewIssueNumbers map[int]int) (*verifyReport, error) {
+	result := &verifyReport{}
+	for _, sourceIssue := range issues {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		newNumber, ok := oldToNewIssueNumbers[sourceIssue.Number]
+		if !ok {
+			log.Printf("Old issue #%d \"%s\" is not in the mapping.", sourceIssue.Number, sourceIssue.Title)
+			result.NotImported = append(result.NotImported, sourceIssue.Number)
+			continue
+		}
+
+		result.Checked++
+		var problems []string
+		target, _, err := im.issues.Get(ctx, im.owner, im.repo, newNumber)
+		if err != nil {
+			problems = []string{fmt.Sprintf("could not be fetched: %v", err)}
+		} else {
+			problems = im.compareIssue(sourceIssue, target)
+		}
+		if len(problems) == 0 {
+			infof("Issue #%d matches old #%d", newNumber, sourceIssue.Number)
+			result.Matched++
+			continue
+		}
+		for _, problem := range problems {
+			log.Printf("Issue #%d (old #%d): %s", newNumber, sourceIssue.Number, problem)
+		}
+		result.Mismatches = append(result.Mismatches, verifyMismatch{Number: newNumber, OldNumber: sourceIssue.Number, Problems: problems})
+	}
+	return result, nil
+}
+
+// auditLabels compares the color and description of every source label with
+// the target label of the same name, matched case-insensitively like GitHub
+// does, and returns the differences without changing anything. A source label
+// missing from the target repository is reported too.
+func (im *importer) auditLabels(ctx context.Context, labels map[string]Label) ([]labelDrift, error) {
+	existingByKey, err := im.existingLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []labelDrift
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		label := labels[name]
+		var problems []string
+		target, ok := existingByKey[strings.ToLower(name)]
+		if !ok {
+			problems = append(problems, "does not exist in the target repository")
+		} else {
+			if color := normalizeLabelColor(name, label.Color); !strings.EqualFold(target.GetColor(), color) {
+				problems = append(problems, fmt.Sprintf("color is %q, expected %q", target.GetColor(), color))
+			}
+			if target.GetDescription() != label.Description {
+				problems = append(problems, fmt.Sprintf("description is %q, expected %q", target.GetDescription(), label.Description))
+			}
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		for _, problem := range problems {
+			log.Printf("Label [%s]: %s", name, problem)
+		}
+		drift = append(drift, labelDrift{Name: name, Problems: problems})
+	}
+	return drift, nil
+}
+
+// compareIssue returns a description of every way target differs from the
+// issue the import creates for source.
+func (im *importer) compareIssue(source Issue, target *github.Issue) []string {
+	var problems []string
+
+	if title := im.issueTitle(source); target.GetTitle() != title {
+		problems = append(problems, fmt.Sprintf("title is %q, expected %q", target.GetTitle(), title))
+	}
+
+	// GitHub matches label names case-insensitively, so an existing label
+	// spelled differently is attached in its own spelling.
+	var want, got []string
+	for _, name := range im.issueLabels(source) {
+		want = append(want, strings.ToLower(name))
+	}
+	for _, label := range target.Labels {
+		got = append(got, strings.ToLower(label.GetName()))
+	}
+	slices.Sort(want)
+	slices.Sort(got)
+	want, got = slices.Compact(want), slices.Compact(got)
+	if !slices.Equal(want, got) {
+		problems = append(problems, fmt.Sprintf("labels are %v, expected %v", got, want))
+	}
+
+	milestone := im.issueMilestone(source)
+	if milestoneKey(target.GetMilestone().GetTitle()) != milestoneKey(milestone) {
+		problems = append(problems, fmt.Sprintf("milestone is %q, expected %q", target.GetMilestone().GetTitle(), milestone))
+	}
+
+	if comments := im.expectedComments(source); target.GetComments() != comments {
+		problems = append(problems, fmt.Sprintf("has %d comments, expected %d", target.GetComments(), comments))
+	}
+	return problems
+}
+
+// expectedComments returns how many comments importIssue posts for an issue:
+// the overflow of a truncated body and the migrated comments, each of them
+// split into as many parts as GitHub's length limit requires, and the
+// --assign-team mention.
+func (im *importer) expectedComments(issue Issue) int {
+	var bodies []string
+	if _, overflow := im.splitSourceBody(issue); overflow != "" {
+		bodies = append(bodies, mapMentions(im.absolutizeURLs(overflow), im.userMap))
+	}
+	if comments := nonEmptyComments(issue.Comments); len(comments) > 0 {
+		if im.separateComments {
+			for _, comment := range comments {
+				bodies = append(bodies, im.separateComment(comment))
+			}
+		} else {
+			bodies = append(bodies, im.consolidatedComments(comments)...)
+		}
+	}
+
+	count := 0
+	if im.teamMention != "" {
+		count++
+	}
+	for _, body := range bodies {
+		count += len(splitBody(body, maxBodyLength))
+	}
+	return count
+}